@@ -0,0 +1,130 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+// logdecode 解码函数日志上报的 compressData，用于排查日志投递问题。
+//
+// 用法：
+//
+//	logdecode [-json] [-strict] [file]
+//
+// 未指定 file 或 file 为 "-" 时从标准输入读取。输入可以是 compressData 本身，
+// 也可以是包含 compressData 字段的 JSON（如 SendLog 请求体、本地 spool 文件）。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/byted-apaas/server-common-go/logger"
+	"github.com/byted-apaas/server-common-go/utils"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "output decoded logs as JSON")
+	strict := flag.Bool("strict", false, "exit with status 2 when issues are found")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-json] [-strict] [file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	payload, err := readInput(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read input failed: %v\n", err)
+		os.Exit(1)
+	}
+	batch, err := logger.DecodeLogBatch(string(payload))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decode failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(batch)
+	} else {
+		printBatch(os.Stdout, batch)
+	}
+
+	if *strict && len(batch.Issues) > 0 {
+		os.Exit(2)
+	}
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func printBatch(w io.Writer, batch *logger.DecodedLogBatch) {
+	for _, log := range batch.Logs {
+		logType := "normal"
+		if log.Type == logger.AggregationLog {
+			logType = "aggregation"
+		}
+		createTime := time.Unix(0, log.CreateTime*int64(time.Millisecond)).Format("2006-01-02 15:04:05.000")
+		fmt.Fprintf(w, "#%d %s %-5s %s %s\n", log.Sequence, createTime, levelName(log.Level), logType, log.RequestID)
+		if log.Content != "" {
+			fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(log.Content, "\n", "\n    "))
+		}
+		if len(log.Fields) > 0 {
+			fmt.Fprintf(w, "    fields: %s\n", formatMap(log.Fields))
+		}
+		if len(log.Tags) > 0 {
+			tags := make([]string, 0, len(log.Tags))
+			for _, tag := range log.Tags {
+				tags = append(tags, tag.Key+"="+tag.Value)
+			}
+			fmt.Fprintf(w, "    tags: %s\n", strings.Join(tags, " "))
+		}
+		if log.Type == logger.AggregationLog {
+			b, _ := utils.JsonMarshalBytes(log.ExtraInfo)
+			fmt.Fprintf(w, "    extraInfo: %s\n", strings.TrimSpace(string(b)))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d logs, %d issues\n", len(batch.Logs), len(batch.Issues))
+	for _, issue := range batch.Issues {
+		fmt.Fprintf(w, "  [index %d, sequence %d] %s\n", issue.Index, issue.Sequence, issue.Message)
+	}
+}
+
+func levelName(level int) string {
+	switch level {
+	case utils.LogLevelError:
+		return "ERROR"
+	case utils.LogLevelWarn:
+		return "WARN"
+	case utils.LogLevelInfo:
+		return "INFO"
+	case utils.LogLevelDebug:
+		return "DEBUG"
+	case utils.LogLevelTrace:
+		return "TRACE"
+	default:
+		return fmt.Sprintf("L%d", level)
+	}
+}
+
+func formatMap(m map[string]interface{}) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, m[k]))
+	}
+	return strings.Join(pairs, " ")
+}
@@ -13,7 +13,14 @@ const (
 	RpcClientRWTimeoutDefault      = 20 * time.Minute
 	APITimeoutDefault              = 12 * time.Second
 
-	AppTokenRefreshRemainTime = 20 * 60 * 1000 // 20m
+	AppTokenRefreshRemainTime      = 20 * 60 * 1000 // 20m
+	AppTokenRefreshJitter          = 5 * 60 * 1000  // 5m，后台刷新在 RemainTime 基础上额外提前的随机时长上限
+	AppTokenRefreshRetryInterval   = 30 * 1000      // 30s，后台刷新失败后的重试间隔，也是后台刷新的最小间隔
+	AppTokenRefreshMaxBackoffShift = 5              // 后台刷新连续失败时重试间隔最多翻倍 5 次，即 16m
+	TenantInfoRefreshInterval      = 60 * 60 * 1000 // 1h，租户信息缓存有效期
+
+	IntegrationTokenRefreshRemainTime = 10 * 60 * 1000 // 10m，飞书集成 token 剩余有效期不足时刷新
+	IntegrationTokenCacheCapacity     = 1024           // 飞书集成 token 缓存的 key 数量上限
 )
 
 const (
@@ -11,6 +11,7 @@ const (
 	EnvKSvcID           = "KSvcID"
 	EnvKClientID        = "KClientID"
 	EnvKClientSecret    = "KClientSecret"
+	EnvKSecretKeys      = "KSecretKeys" // 加密 KClientID/KClientSecret 的密钥，格式：keyID:base64Key,keyID:base64Key
 	EnvKOpenApiDomain   = "KOpenApiDomain"
 	EnvKFaaSInfraDomain = "KFaaSInfraDomain"
 	EnvKInnerAPIDomain  = "KInnerAPIDomain"
@@ -72,6 +73,7 @@ const (
 
 	PressureNeedDecelerateHeader = "x-serverless-sdk-pressure-need-decelerate" // 反压中心是否需要降速，由CloudFunction下发该开关
 	PressureConfigHeader         = "x-serverless-sdk-pressure-config"          // 反压中心相关配置，由CloudFunction下发该配置
+	PressureSignalHeader         = "x-serverless-sdk-pressure-signal"          // 反压中心压力信号，由 OpenAPI 响应头下发，格式：{"<signalId>": <sleeptime(ms)>}
 
 	HeaderSDKCallLog       = "x-serverless-sdk-call-log"
 	HeaderSDKCallLogDetail = "x-serverless-sdk-call-log-detail"
@@ -134,10 +136,12 @@ const (
 )
 
 const (
-	APaaSLogPrefix   = "apaas-log-prefix"
-	APaaSLogSuffix   = "apaas-log-suffix"
-	UserLogType      = "user"
-	RateLimitLogType = "rate_limit" // SDK 限流
-	SpeedDownLogType = "speed_down" // SDK 降速
-	SDKCallLogType   = "sdk_call"   // SDK 请求
+	APaaSLogPrefix    = "apaas-log-prefix"
+	APaaSLogSuffix    = "apaas-log-suffix"
+	UserLogType       = "user"
+	RateLimitLogType  = "rate_limit" // SDK 限流
+	SpeedDownLogType  = "speed_down" // SDK 降速
+	SDKCallLogType    = "sdk_call"   // SDK 请求
+	PressureLogType   = "pressure"   // SDK 反压降速器
+	CredentialLogType = "credential" // SDK 凭证刷新
 )
@@ -40,4 +40,10 @@ const (
 	CtxKeySDKConf          = "x-apaas-sdk-conf"
 	CtxKeyRuntimeType      = "KRuntimeType"
 	CtxKeyPressureReqTag   = "__PressureReqTag__"
+	CtxKeyRequestPriority  = "__RequestPriority__"
+	CtxKeyAuthReplayTag    = "__AuthReplayTag__"
+	CtxKeyIntegrationToken = "__IntegrationTokenCache__"
+	CtxKeyClientType       = "__ClientType__"
+	CtxKeyLogLevel         = "__LogLevel__"
+	CtxKeyRedactor         = "__Redactor__"
 )
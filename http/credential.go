@@ -5,46 +5,173 @@ package http
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/byted-apaas/server-common-go/constants"
 	exp "github.com/byted-apaas/server-common-go/exceptions"
 	"github.com/byted-apaas/server-common-go/structs"
 	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
 )
 
+// ICredential 请求鉴权凭证，AppTokenMiddleware 通过其获取 token
 type ICredential interface {
-	getToken(ctx context.Context) (string, error)
-	setSystemFlag(ctx context.Context, isSystem bool)
+	// GetToken 获取当前可用的 token
+	GetToken(ctx context.Context) (string, error)
+	// InvalidateToken 服务端拒绝 token 时调用，仅当缓存仍为该 token 时失效并返回 true
+	InvalidateToken(token string) bool
+}
+
+// identifiedCredential 可提供稳定身份标识的凭证，用于按凭证缓存由其派生的 token
+type identifiedCredential interface {
+	CredentialID() string
+}
+
+// credentialIdentity 获取凭证的身份标识，nil 表示默认凭证；未提供标识的凭证返回 false
+func credentialIdentity(credential ICredential) (string, bool) {
+	if isNilCredential(credential) {
+		return "", true
+	}
+	if c, ok := credential.(identifiedCredential); ok {
+		return c.CredentialID(), true
+	}
+	return "", false
 }
 
 type AppCredential struct {
 	id, secret string
 
-	tenantInfo atomic.Value // TenantInfo
-	token      atomic.Value // string
-	expireTime atomic.Value // int64
+	tenantInfo       atomic.Value // TenantInfo
+	tenantUpdateTime int64        // 租户信息更新时间，单位：ms
+	tenantTTL        int64        // 租户信息有效期，单位：ms，<= 0 表示不过期
+	tenantHooks      []func(old, new *structs.Tenant)
+	token            atomic.Value // string
+	expireTime       atomic.Value // int64
+
+	lock         sync.Mutex
+	isSystem     bool
+	serviceToken bool // FaaSInfra service token，响应中不包含租户信息
+	clock        clock.Clock
+	fetch        func(ctx context.Context, clientID, clientSecret string) (*structs.AppTokenResp, error)
+
+	inflight *refreshCall // 进行中的刷新，并发调用方共享同一次刷新
+
+	// 后台刷新，需通过 StartBackgroundRefresh 显式开启
+	backgroundRefresh bool
+	refresherRunning  bool
+	closed            bool
+	closeCh           chan struct{}
+	wg                sync.WaitGroup
+
+	// 刷新指标
+	refreshes          int64
+	refreshErrors      int64
+	sharedWaits        int64
+	staleServed        int64
+	invalidations      int64
+	lastRefreshTime    int64
+	lastRefreshLatency int64
+	lastRefreshError   atomic.Value // string
+}
+
+// refreshCall 一次进行中的 token 刷新
+type refreshCall struct {
+	done   chan struct{}
+	token  string
+	tenant *structs.Tenant
+	err    error
+}
 
-	lock     sync.Mutex
-	isSystem bool
+// CredentialStats 凭证刷新指标
+type CredentialStats struct {
+	Refreshes          int64  `json:"refreshes"`            // 实际发起的刷新次数
+	RefreshErrors      int64  `json:"refresh_errors"`       // 刷新失败次数
+	SharedWaits        int64  `json:"shared_waits"`         // 复用进行中刷新的次数
+	StaleServed        int64  `json:"stale_served"`         // 刷新失败后返回未过期旧 token 的次数
+	Invalidations      int64  `json:"invalidations"`        // token 被服务端拒绝后失效的次数
+	LastRefreshTime    int64  `json:"last_refresh_time"`    // 最近一次刷新时间，单位：ms
+	LastRefreshLatency int64  `json:"last_refresh_latency"` // 最近一次刷新耗时，单位：ms
+	LastRefreshError   string `json:"last_refresh_error,omitempty"`
+	ExpireTime         int64  `json:"expire_time"` // 当前 token 过期时间，单位：ms
+	BackgroundRefresh  bool   `json:"background_refresh"`
 }
 
 func NewAppCredential(id, secret string) *AppCredential {
 	return &AppCredential{
-		id:     id,
-		secret: secret,
-		lock:   sync.Mutex{},
+		id:        id,
+		secret:    secret,
+		lock:      sync.Mutex{},
+		clock:     clock.Default(),
+		fetch:     GetAppTokenHttp,
+		closeCh:   make(chan struct{}),
+		tenantTTL: constants.TenantInfoRefreshInterval,
+	}
+}
+
+// NewServiceCredential 使用 clientID/clientSecret 从 FaaSInfra 换取 service token 的凭证，缓存与刷新策略与 AppCredential 一致
+func NewServiceCredential(id, secret string) *AppCredential {
+	c := NewAppCredential(id, secret)
+	c.serviceToken = true
+	c.fetch = func(ctx context.Context, clientID, clientSecret string) (*structs.AppTokenResp, error) {
+		resp, err := GetServiceTokenHttp(ctx, clientID, clientSecret)
+		if err != nil {
+			return nil, err
+		}
+		return &structs.AppTokenResp{AccessToken: resp.AccessToken, ExpireTime: resp.ExpireTime}, nil
+	}
+	return c
+}
+
+// SetTenantInfoTTL 设置租户信息缓存有效期，过期后 GetTenantInfo 随 token 一起刷新，ttl <= 0 表示不过期
+func (c *AppCredential) SetTenantInfoTTL(ttl time.Duration) {
+	atomic.StoreInt64(&c.tenantTTL, ttl.Milliseconds())
+}
+
+// OnTenantInfoChange 注册租户信息变化回调，刷新后租户名称、域名、命名空间等发生变化时调用。
+// 回调在刷新流程中同步执行，不要在回调中同步调用 RefreshTenantInfo
+func (c *AppCredential) OnTenantInfoChange(hook func(old, new *structs.Tenant)) {
+	if hook == nil {
+		return
 	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tenantHooks = append(c.tenantHooks, hook)
+}
+
+// SetClock 替换时钟，用于测试 token 过期与刷新
+func (c *AppCredential) SetClock(clk clock.Clock) {
+	c.clock = clock.OrDefault(clk)
+}
+
+func (c *AppCredential) nowMils() int64 {
+	return utils.TimeMils(clock.OrDefault(c.clock).Now())
 }
 
 func (c *AppCredential) GetID() string {
 	return c.id
 }
 
+// CredentialID 凭证身份标识，同一 clientID 的 app token 与 service token 凭证互不相同
+func (c *AppCredential) CredentialID() string {
+	if c.serviceToken {
+		return "service:" + c.id
+	}
+	return "app:" + c.id
+}
+
+// GetToken 获取 token，剩余有效期不足时刷新
+func (c *AppCredential) GetToken(ctx context.Context) (string, error) {
+	return c.getToken(ctx)
+}
+
 func (c *AppCredential) getToken(ctx context.Context) (string, error) {
 	expireTime, ok := c.expireTime.Load().(int64)
-	if ok && expireTime-utils.NowMils() > constants.AppTokenRefreshRemainTime {
+	if ok && expireTime-c.nowMils() > constants.AppTokenRefreshRemainTime {
 		token, ok := c.token.Load().(string)
 		if ok {
 			return token, nil
@@ -52,7 +179,41 @@ func (c *AppCredential) getToken(ctx context.Context) (string, error) {
 	}
 
 	token, _, err := c.refresh(ctx)
-	return token, err
+	if err != nil {
+		// 刷新失败但旧 token 尚未过期，继续使用旧 token
+		if cached, ok := c.validToken(); ok {
+			atomic.AddInt64(&c.staleServed, 1)
+			return cached, nil
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+// InvalidateToken 服务端拒绝 token 时失效缓存，仅当缓存仍为该 token 时生效，避免误失效并发刷新得到的新 token
+func (c *AppCredential) InvalidateToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if cached, _ := c.token.Load().(string); cached != token {
+		return false
+	}
+	c.token.Store("")
+	c.expireTime.Store(int64(0))
+	atomic.AddInt64(&c.invalidations, 1)
+	return true
+}
+
+// validToken 返回未过期的缓存 token
+func (c *AppCredential) validToken() (string, bool) {
+	expireTime, ok := c.expireTime.Load().(int64)
+	if !ok || expireTime <= c.nowMils() {
+		return "", false
+	}
+	token, ok := c.token.Load().(string)
+	return token, ok && token != ""
 }
 
 func (c *AppCredential) setSystemFlag(ctx context.Context, isSystem bool) {
@@ -60,7 +221,7 @@ func (c *AppCredential) setSystemFlag(ctx context.Context, isSystem bool) {
 }
 
 func (c *AppCredential) GetTenantInfo(ctx context.Context) (*structs.Tenant, error) {
-	if c == nil {
+	if c == nil || c.serviceToken {
 		credential, err := getFaaSCredential()
 		if err != nil {
 			return nil, exp.InternalError("get system credential failed: " + err.Error())
@@ -68,45 +229,257 @@ func (c *AppCredential) GetTenantInfo(ctx context.Context) (*structs.Tenant, err
 		c = credential
 	}
 	tenant, ok := c.tenantInfo.Load().(*structs.Tenant)
-	if ok {
+	if ok && !c.tenantInfoExpired() {
 		return tenant, nil
 	}
+	tenantInfo, err := c.RefreshTenantInfo(ctx)
+	if err != nil && ok {
+		// 刷新失败时继续使用已缓存的租户信息
+		return tenant, nil
+	}
+	return tenantInfo, err
+}
+
+// RefreshTenantInfo 强制刷新 token 与租户信息
+func (c *AppCredential) RefreshTenantInfo(ctx context.Context) (*structs.Tenant, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	// fix: 修复进程启动后第一个请求为GetTenantInfo且触发反压时的死锁问题
 	ctx = withPressureSdkReqTag(ctx) // 添加反压标识，防止refresh.lock死锁
 	_, tenantInfo, err := c.refresh(ctx)
 	return tenantInfo, err
 }
 
+func (c *AppCredential) tenantInfoExpired() bool {
+	ttl := atomic.LoadInt64(&c.tenantTTL)
+	return ttl > 0 && c.nowMils()-atomic.LoadInt64(&c.tenantUpdateTime) >= ttl
+}
+
+// refresh 刷新 token，并发调用方共享同一次进行中的刷新
 func (c *AppCredential) refresh(ctx context.Context) (token string, tenantInfo *structs.Tenant, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	c.lock.Lock()
-	defer c.lock.Unlock()
+	if call := c.inflight; call != nil {
+		c.lock.Unlock()
+		atomic.AddInt64(&c.sharedWaits, 1)
+		select {
+		case <-call.done:
+			return call.token, call.tenant, call.err
+		case <-ctx.Done():
+			return "", nil, exp.InternalError("[AppCredential] wait for token refresh failed: %v", ctx.Err())
+		}
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	c.inflight = call
+	c.lock.Unlock()
+
+	call.token, call.tenant, call.err = c.doRefresh(ctx)
+
+	c.lock.Lock()
+	c.inflight = nil
+	c.lock.Unlock()
+	close(call.done)
+
+	if call.err == nil {
+		c.startRefresher()
+	}
+	return call.token, call.tenant, call.err
+}
 
+func (c *AppCredential) doRefresh(ctx context.Context) (token string, tenantInfo *structs.Tenant, err error) {
+	clk := clock.OrDefault(c.clock)
+	start := clk.Now()
 	resp, err := c.fetchToken(ctx)
+	atomic.AddInt64(&c.refreshes, 1)
+	atomic.StoreInt64(&c.lastRefreshTime, utils.TimeMils(start))
+	atomic.StoreInt64(&c.lastRefreshLatency, clk.Since(start).Milliseconds())
 	if err != nil {
+		atomic.AddInt64(&c.refreshErrors, 1)
+		c.lastRefreshError.Store(err.Error())
 		return "", nil, err
 	}
+	c.lastRefreshError.Store("")
+
 	c.token.Store(resp.AccessToken)
 	c.expireTime.Store(resp.ExpireTime)
-	tenant, ok := c.tenantInfo.Load().(*structs.Tenant)
+	if c.serviceToken {
+		tenantInfo, _ = c.tenantInfo.Load().(*structs.Tenant)
+		return resp.AccessToken, tenantInfo, nil
+	}
+	tenantInfo = c.updateTenantInfo(&structs.Tenant{
+		ID:        resp.TenantInfo.ID,
+		Name:      resp.TenantInfo.DomainName,
+		Type:      resp.TenantInfo.TenantType,
+		Namespace: resp.Namespace,
+		Domain:    resp.TenantInfo.OutsideTenantInfo.OutsideDomainName,
+	})
+	return resp.AccessToken, tenantInfo, nil
+}
+
+// updateTenantInfo 更新租户信息，内容变化时通知回调；内容不变时保留原对象
+func (c *AppCredential) updateTenantInfo(tenant *structs.Tenant) *structs.Tenant {
+	atomic.StoreInt64(&c.tenantUpdateTime, c.nowMils())
+	old, ok := c.tenantInfo.Load().(*structs.Tenant)
+	if ok && *old == *tenant {
+		return old
+	}
+	c.tenantInfo.Store(tenant)
 	if !ok {
-		tenantInfo = &structs.Tenant{
-			ID:        resp.TenantInfo.ID,
-			Name:      resp.TenantInfo.DomainName,
-			Type:      resp.TenantInfo.TenantType,
-			Namespace: resp.Namespace,
-			Domain:    resp.TenantInfo.OutsideTenantInfo.OutsideDomainName,
+		return tenant
+	}
+
+	c.lock.Lock()
+	hooks := append([]func(old, new *structs.Tenant){}, c.tenantHooks...)
+	c.lock.Unlock()
+	for _, hook := range hooks {
+		func() {
+			defer utils.PanicGuard(context.Background())
+			hook(old, tenant)
+		}()
+	}
+	return tenant
+}
+
+// StartBackgroundRefresh 开启后台刷新，在 token 过期前提前续期，需调用 Close 停止。
+// 仅用于进程级长期持有的凭证（如系统凭证、CredentialRegistry），按请求创建的凭证不要开启，避免泄漏 goroutine
+func (c *AppCredential) StartBackgroundRefresh() {
+	c.lock.Lock()
+	c.backgroundRefresh = true
+	c.lock.Unlock()
+
+	if _, ok := c.validToken(); ok {
+		c.startRefresher()
+	}
+}
+
+// startRefresher 开启后台刷新且刷新成功后启动后台刷新任务
+func (c *AppCredential) startRefresher() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.backgroundRefresh || c.refresherRunning || c.closed || c.closeCh == nil {
+		return
+	}
+	c.refresherRunning = true
+	c.wg.Add(1)
+	go c.runRefresher(c.closeCh)
+}
+
+func (c *AppCredential) runRefresher(closeCh <-chan struct{}) {
+	defer c.wg.Done()
+	defer func() {
+		c.lock.Lock()
+		c.refresherRunning = false
+		c.lock.Unlock()
+	}()
+
+	clk := clock.OrDefault(c.clock)
+	failures := 0 // 连续刷新失败次数
+	for {
+		delay, ok := c.nextRefreshDelay(failures)
+		if !ok { // token 已过期且刷新失败，交回请求路径按需刷新
+			return
+		}
+		timer := clk.NewTimer(delay)
+		select {
+		case <-closeCh:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		ctx := withPressureSdkReqTag(context.Background())
+		if _, _, err := c.refresh(ctx); err != nil {
+			failures++
+			fmt.Println(utils.NewFormatLog(ctx, utils.LogLevelWarn, constants.CredentialLogType,
+				fmt.Sprintf("[AppCredential] background refresh token failed, clientID: %s, err: %v", c.id, err)).String())
+		} else {
+			failures = 0
+		}
+	}
+}
+
+// nextRefreshDelay 计算下一次后台刷新的等待时长，在 RemainTime 基础上随机提前以打散刷新时间。
+// 等待时长不小于 AppTokenRefreshRetryInterval；连续失败时指数退避；token 有效期不超过 RemainTime 时在剩余一半时刷新
+func (c *AppCredential) nextRefreshDelay(failures int) (time.Duration, bool) {
+	expireTime, _ := c.expireTime.Load().(int64)
+	remain := expireTime - c.nowMils()
+	minDelay := int64(constants.AppTokenRefreshRetryInterval)
+	if failures > 0 {
+		if remain <= 0 {
+			return 0, false
+		}
+		shift := failures - 1
+		if shift > constants.AppTokenRefreshMaxBackoffShift {
+			shift = constants.AppTokenRefreshMaxBackoffShift
+		}
+		delay := minDelay << uint(shift)
+		if delay > remain {
+			delay = remain
 		}
-		c.tenantInfo.Store(tenantInfo)
+		return time.Duration(delay) * time.Millisecond, true
+	}
+
+	var delay int64
+	if remain <= constants.AppTokenRefreshRemainTime {
+		delay = remain / 2
 	} else {
-		tenantInfo = tenant
+		delay = remain - constants.AppTokenRefreshRemainTime - rand.Int63n(constants.AppTokenRefreshJitter+1)
 	}
-	return resp.AccessToken, tenantInfo, nil
+	if delay < minDelay {
+		delay = minDelay
+	}
+	return time.Duration(delay) * time.Millisecond, true
+}
+
+// Stats 获取刷新指标
+func (c *AppCredential) Stats() CredentialStats {
+	c.lock.Lock()
+	running := c.refresherRunning
+	c.lock.Unlock()
+	lastErr, _ := c.lastRefreshError.Load().(string)
+	expireTime, _ := c.expireTime.Load().(int64)
+	return CredentialStats{
+		Refreshes:          atomic.LoadInt64(&c.refreshes),
+		RefreshErrors:      atomic.LoadInt64(&c.refreshErrors),
+		SharedWaits:        atomic.LoadInt64(&c.sharedWaits),
+		StaleServed:        atomic.LoadInt64(&c.staleServed),
+		Invalidations:      atomic.LoadInt64(&c.invalidations),
+		LastRefreshTime:    atomic.LoadInt64(&c.lastRefreshTime),
+		LastRefreshLatency: atomic.LoadInt64(&c.lastRefreshLatency),
+		LastRefreshError:   lastErr,
+		ExpireTime:         expireTime,
+		BackgroundRefresh:  running,
+	}
+}
+
+// Close 停止后台刷新，之后 token 仅在请求时按需刷新
+func (c *AppCredential) Close() {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return
+	}
+	c.closed = true
+	if c.closeCh != nil {
+		close(c.closeCh)
+	}
+	c.lock.Unlock()
+	c.wg.Wait()
 }
 
 func (c *AppCredential) fetchToken(ctx context.Context) (result *structs.AppTokenResp, err error) {
 
 	ctx = utils.SetApiTimeoutMethodToCtx(ctx, constants.GetAppToken)
-	result, err = GetAppTokenHttp(ctx, c.id, c.secret)
+	ctx = utils.SetRequestPriorityToCtx(ctx, utils.RequestPriorityInfrastructure)
+	fetch := c.fetch
+	if fetch == nil {
+		fetch = GetAppTokenHttp
+	}
+	result, err = fetch(ctx, c.id, c.secret)
 
 	if err != nil {
 		return nil, err
@@ -117,6 +490,9 @@ func (c *AppCredential) fetchToken(ctx context.Context) (result *structs.AppToke
 
 var (
 	faaSTokenInstance *AppCredential
+
+	faaSInfraCredentialOnce sync.Once
+	faaSInfraCredential     *serviceFallbackCredential
 )
 
 func getFaaSCredential() (*AppCredential, error) {
@@ -131,20 +507,132 @@ func getFaaSCredential() (*AppCredential, error) {
 
 	faaSTokenInstance = NewAppCredential(appID, appSecret)
 	faaSTokenInstance.setSystemFlag(context.Background(), true)
+	faaSTokenInstance.StartBackgroundRefresh()
 
 	return faaSTokenInstance, nil
 }
 
-func getCredentialFromCtx(ctx context.Context) *AppCredential {
+func getCredentialFromCtx(ctx context.Context) ICredential {
 	if ctx == nil {
 		return nil
 	}
-	tokenInstance, _ := ctx.Value(constants.CtxKeyCredential).(*AppCredential)
-	return tokenInstance
+	credential, _ := ctx.Value(constants.CtxKeyCredential).(ICredential)
+	return credential
 }
 
-func SetCredentialToCtx(ctx context.Context, credential *AppCredential) context.Context {
+var (
+	defaultCredential atomic.Value // credentialHolder
+)
+
+type credentialHolder struct {
+	credential ICredential
+}
+
+// SetDefaultCredential 设置 ctx 中未指定凭证时使用的默认凭证，传 nil 恢复为从环境变量解密的系统凭证
+func SetDefaultCredential(credential ICredential) {
+	if isNilCredential(credential) {
+		credential = nil
+	}
+	defaultCredential.Store(credentialHolder{credential: credential})
+}
+
+// GetCredentialIDFromCtx 获取 ctx 发起的请求所用凭证的身份标识，优先级同 resolveCredential，系统凭证为 "system"；
+// 凭证未提供身份标识时返回 false
+func GetCredentialIDFromCtx(ctx context.Context) (string, bool) {
+	if credential := getCredentialFromCtx(ctx); credential != nil {
+		return credentialIdentity(credential)
+	}
+	if holder, _ := defaultCredential.Load().(credentialHolder); holder.credential != nil {
+		return credentialIdentity(holder.credential)
+	}
+	return "system", true
+}
+
+// resolveCredential 获取请求使用的凭证，优先级：ctx > 默认凭证 > 系统凭证。
+// 开启 service token 时 FaaSInfra 请求的系统凭证为 service token，获取失败时回退到 app token
+func resolveCredential(ctx context.Context) (ICredential, error) {
+	if credential := getCredentialFromCtx(ctx); credential != nil {
+		return credential, nil
+	}
+	if holder, _ := defaultCredential.Load().(credentialHolder); holder.credential != nil {
+		return holder.credential, nil
+	}
+	if getClientTypeFromCtx(ctx) == FaaSInfraClient && isServiceTokenEnabled() {
+		return getFaaSInfraCredential()
+	}
+	return getFaaSCredential()
+}
+
+func withClientType(ctx context.Context, clientType ClientType) context.Context {
+	return context.WithValue(ctx, constants.CtxKeyClientType, clientType)
+}
+
+func getClientTypeFromCtx(ctx context.Context) ClientType {
+	if ctx == nil {
+		return 0
+	}
+	clientType, _ := ctx.Value(constants.CtxKeyClientType).(ClientType)
+	return clientType
+}
+
+func getFaaSInfraCredential() (*serviceFallbackCredential, error) {
+	appCredential, err := getFaaSCredential()
+	if err != nil {
+		return nil, err
+	}
+	faaSInfraCredentialOnce.Do(func() {
+		service := NewServiceCredential(appCredential.id, appCredential.secret)
+		service.setSystemFlag(context.Background(), true)
+		service.StartBackgroundRefresh()
+		faaSInfraCredential = &serviceFallbackCredential{service: service, fallback: appCredential}
+	})
+	return faaSInfraCredential, nil
+}
+
+// serviceFallbackCredential 优先使用 service token，获取失败时回退到 app token，并在重试间隔内跳过 service token。
+// FaaSInfra 不支持 service token 接口时，当前进程不再尝试 service token
+type serviceFallbackCredential struct {
+	service, fallback *AppCredential
+	skipUntil         int64 // 单位：ms
+	unsupported       int32
+}
+
+func (c *serviceFallbackCredential) GetToken(ctx context.Context) (string, error) {
+	if atomic.LoadInt32(&c.unsupported) == 0 && c.service.nowMils() >= atomic.LoadInt64(&c.skipUntil) {
+		token, err := c.service.GetToken(ctx)
+		if err == nil {
+			return token, nil
+		}
+		if errors.Is(err, errServiceTokenUnsupported) {
+			if atomic.CompareAndSwapInt32(&c.unsupported, 0, 1) {
+				c.service.Close()
+				fmt.Println(utils.NewFormatLog(ctx, utils.LogLevelInfo, constants.CredentialLogType,
+					"[ServiceCredential] service token is not supported, use app token").String())
+			}
+		} else {
+			atomic.StoreInt64(&c.skipUntil, c.service.nowMils()+constants.AppTokenRefreshRetryInterval)
+			fmt.Println(utils.NewFormatLog(ctx, utils.LogLevelWarn, constants.CredentialLogType,
+				fmt.Sprintf("[ServiceCredential] get service token failed, fallback to app token, err: %v", err)).String())
+		}
+	}
+	return c.fallback.GetToken(ctx)
+}
+
+func (c *serviceFallbackCredential) InvalidateToken(token string) bool {
+	invalidated := c.service.InvalidateToken(token)
+	return c.fallback.InvalidateToken(token) || invalidated
+}
+
+func isNilCredential(credential ICredential) bool {
 	if credential == nil {
+		return true
+	}
+	c, ok := credential.(*AppCredential)
+	return ok && c == nil
+}
+
+func SetCredentialToCtx(ctx context.Context, credential ICredential) context.Context {
+	if isNilCredential(credential) {
 		return ctx
 	}
 	if ctx == nil {
@@ -0,0 +1,199 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	exp "github.com/byted-apaas/server-common-go/exceptions"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+// StaticCredential 固定 token，适用于由外部密钥管理下发的 token
+type StaticCredential struct {
+	token string
+}
+
+func NewStaticCredential(token string) *StaticCredential {
+	return &StaticCredential{token: token}
+}
+
+func (c *StaticCredential) GetToken(ctx context.Context) (string, error) {
+	if c.token == "" {
+		return "", exp.InternalError("[StaticCredential] token is empty")
+	}
+	return c.token, nil
+}
+
+// CredentialID 以 token 摘要作为身份标识，不暴露 token 本身
+func (c *StaticCredential) CredentialID() string {
+	sum := sha256.Sum256([]byte(c.token))
+	return "static:" + hex.EncodeToString(sum[:8])
+}
+
+// InvalidateToken 固定 token 无法刷新，始终返回 false
+func (c *StaticCredential) InvalidateToken(token string) bool {
+	return false
+}
+
+// FileCredentialCheckInterval 文件 token 变更检查间隔
+const FileCredentialCheckInterval = time.Second
+
+// FileCredential 从文件读取 token，文件修改后自动重新加载
+type FileCredential struct {
+	path  string
+	clock clock.Clock
+
+	lock      sync.Mutex
+	token     string
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func NewFileCredential(path string) *FileCredential {
+	return &FileCredential{path: path, clock: clock.Default()}
+}
+
+// SetClock 替换时钟，用于测试文件变更检查
+func (c *FileCredential) SetClock(clk clock.Clock) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.clock = clock.OrDefault(clk)
+}
+
+func (c *FileCredential) GetToken(ctx context.Context) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := clock.OrDefault(c.clock).Now()
+	if c.token != "" && now.Sub(c.lastCheck) < FileCredentialCheckInterval {
+		return c.token, nil
+	}
+	c.lastCheck = now
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return "", exp.InternalError("[FileCredential] stat token file %s failed, err: %v", c.path, err)
+	}
+	if c.token != "" && info.ModTime().Equal(c.modTime) {
+		return c.token, nil
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return "", exp.InternalError("[FileCredential] read token file %s failed, err: %v", c.path, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", exp.InternalError("[FileCredential] token file %s is empty", c.path)
+	}
+	c.token = token
+	c.modTime = info.ModTime()
+	return c.token, nil
+}
+
+func (c *FileCredential) CredentialID() string {
+	return "file:" + c.path
+}
+
+// InvalidateToken 丢弃缓存，下次获取时重新读取文件
+func (c *FileCredential) InvalidateToken(token string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if token == "" || c.token != token {
+		return false
+	}
+	c.token = ""
+	c.modTime = time.Time{}
+	return true
+}
+
+// EnvCredential 使用环境变量中加密的 clientID/clientSecret 换取 token，即系统默认凭证
+type EnvCredential struct{}
+
+func NewEnvCredential() *EnvCredential {
+	return &EnvCredential{}
+}
+
+func (c *EnvCredential) GetToken(ctx context.Context) (string, error) {
+	credential, err := getFaaSCredential()
+	if err != nil {
+		return "", err
+	}
+	return credential.GetToken(ctx)
+}
+
+func (c *EnvCredential) CredentialID() string {
+	return "env"
+}
+
+func (c *EnvCredential) InvalidateToken(token string) bool {
+	credential, err := getFaaSCredential()
+	if err != nil {
+		return false
+	}
+	return credential.InvalidateToken(token)
+}
+
+// ChainCredential 依次尝试多个凭证，返回第一个成功获取的 token
+type ChainCredential struct {
+	providers []ICredential
+}
+
+func NewChainCredential(providers ...ICredential) *ChainCredential {
+	chain := &ChainCredential{}
+	for _, provider := range providers {
+		if !isNilCredential(provider) {
+			chain.providers = append(chain.providers, provider)
+		}
+	}
+	return chain
+}
+
+func (c *ChainCredential) GetToken(ctx context.Context) (string, error) {
+	if len(c.providers) == 0 {
+		return "", exp.InternalError("[ChainCredential] no credential provider")
+	}
+	errs := make([]string, 0, len(c.providers))
+	for _, provider := range c.providers {
+		token, err := provider.GetToken(ctx)
+		if err == nil && token != "" {
+			return token, nil
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return "", exp.InternalError("[ChainCredential] all credential providers failed: %s", strings.Join(errs, "; "))
+}
+
+// CredentialID 由各凭证的标识组成，存在未提供标识的凭证时返回空
+func (c *ChainCredential) CredentialID() string {
+	ids := make([]string, 0, len(c.providers))
+	for _, provider := range c.providers {
+		id, ok := credentialIdentity(provider)
+		if !ok {
+			return ""
+		}
+		ids = append(ids, id)
+	}
+	return "chain(" + strings.Join(ids, ",") + ")"
+}
+
+// InvalidateToken 通知所有凭证，由持有该 token 的凭证自行失效
+func (c *ChainCredential) InvalidateToken(token string) bool {
+	invalidated := false
+	for _, provider := range c.providers {
+		if provider.InvalidateToken(token) {
+			invalidated = true
+		}
+	}
+	return invalidated
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package http
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/byted-apaas/server-common-go/structs"
+)
+
+// DefaultCredentialRegistryCapacity 凭证注册表默认容量
+const DefaultCredentialRegistryCapacity = 128
+
+// CredentialRegistry 多应用凭证注册表，按 clientID 缓存 AppCredential，超出容量时淘汰最久未使用的应用。
+// 同一应用的并发请求共享同一个 AppCredential，从而共享 token 刷新与租户信息缓存。
+type CredentialRegistry struct {
+	capacity int
+
+	lock       sync.Mutex
+	lru        *list.List               // *registryEntry，头部为最近使用
+	entries    map[string]*list.Element // clientID -> entry
+	namespaces map[string]string        // namespace -> clientID
+}
+
+type registryEntry struct {
+	clientID, secret string
+	credential       *AppCredential
+}
+
+// NewCredentialRegistry capacity <= 0 时使用默认容量
+func NewCredentialRegistry(capacity int) *CredentialRegistry {
+	if capacity <= 0 {
+		capacity = DefaultCredentialRegistryCapacity
+	}
+	return &CredentialRegistry{
+		capacity:   capacity,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		namespaces: make(map[string]string),
+	}
+}
+
+// Get 获取应用凭证，不存在或 secret 变化时新建
+func (r *CredentialRegistry) Get(clientID, clientSecret string) *AppCredential {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if elem, ok := r.entries[clientID]; ok {
+		entry := elem.Value.(*registryEntry)
+		if entry.secret == clientSecret {
+			r.lru.MoveToFront(elem)
+			return entry.credential
+		}
+		r.removeElement(elem)
+	}
+
+	entry := &registryEntry{clientID: clientID, secret: clientSecret, credential: NewAppCredential(clientID, clientSecret)}
+	entry.credential.StartBackgroundRefresh()
+	r.entries[clientID] = r.lru.PushFront(entry)
+	for r.lru.Len() > r.capacity {
+		r.removeElement(r.lru.Back())
+	}
+	return entry.credential
+}
+
+// Lookup 按 clientID 查找已注册的应用凭证
+func (r *CredentialRegistry) Lookup(clientID string) (*AppCredential, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	elem, ok := r.entries[clientID]
+	if !ok {
+		return nil, false
+	}
+	r.lru.MoveToFront(elem)
+	return elem.Value.(*registryEntry).credential, true
+}
+
+// LookupByNamespace 按命名空间查找应用凭证，命名空间来自获取 token 时返回的租户信息，未获取过 token 的应用无法命中
+func (r *CredentialRegistry) LookupByNamespace(namespace string) (*AppCredential, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if clientID, ok := r.namespaces[namespace]; ok {
+		if elem, ok := r.entries[clientID]; ok && namespaceOf(elem.Value.(*registryEntry).credential) == namespace {
+			r.lru.MoveToFront(elem)
+			return elem.Value.(*registryEntry).credential, true
+		}
+		delete(r.namespaces, namespace)
+	}
+
+	for elem := r.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*registryEntry)
+		if namespaceOf(entry.credential) == namespace {
+			r.namespaces[namespace] = entry.clientID
+			r.lru.MoveToFront(elem)
+			return entry.credential, true
+		}
+	}
+	return nil, false
+}
+
+// GetTenantInfo 获取应用的租户信息，使用该应用凭证缓存的结果
+func (r *CredentialRegistry) GetTenantInfo(ctx context.Context, clientID, clientSecret string) (*structs.Tenant, error) {
+	return r.Get(clientID, clientSecret).GetTenantInfo(ctx)
+}
+
+// WithCredential 将应用凭证设置到 ctx，供 AppTokenMiddleware 使用
+func (r *CredentialRegistry) WithCredential(ctx context.Context, clientID, clientSecret string) context.Context {
+	return SetCredentialToCtx(ctx, r.Get(clientID, clientSecret))
+}
+
+// Remove 移除应用凭证并停止其后台刷新
+func (r *CredentialRegistry) Remove(clientID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if elem, ok := r.entries[clientID]; ok {
+		r.removeElement(elem)
+	}
+}
+
+// Len 当前缓存的应用数量
+func (r *CredentialRegistry) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.lru.Len()
+}
+
+// Close 移除所有应用凭证
+func (r *CredentialRegistry) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for r.lru.Len() > 0 {
+		r.removeElement(r.lru.Back())
+	}
+}
+
+func (r *CredentialRegistry) removeElement(elem *list.Element) {
+	entry := r.lru.Remove(elem).(*registryEntry)
+	delete(r.entries, entry.clientID)
+	if namespace := namespaceOf(entry.credential); namespace != "" && r.namespaces[namespace] == entry.clientID {
+		delete(r.namespaces, namespace)
+	}
+	// 被淘汰的凭证可能仍被 ctx 持有，Close 仅停止后台刷新，请求时仍可按需刷新
+	go entry.credential.Close()
+}
+
+func namespaceOf(credential *AppCredential) string {
+	tenant, ok := credential.tenantInfo.Load().(*structs.Tenant)
+	if !ok || tenant == nil {
+		return ""
+	}
+	return tenant.Namespace
+}
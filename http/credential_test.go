@@ -0,0 +1,452 @@
+package http
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/byted-apaas/server-common-go/constants"
+	exp "github.com/byted-apaas/server-common-go/exceptions"
+	"github.com/byted-apaas/server-common-go/structs"
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+type stubTokenFetcher struct {
+	fc      *clock.Fake
+	count   int64
+	release chan struct{}
+	err     atomic.Value // stubErr
+	ns      string
+	ttl     time.Duration // token 有效期，0 表示 1h
+}
+
+type stubErr struct {
+	err error
+}
+
+func (f *stubTokenFetcher) fetch(ctx context.Context, clientID, clientSecret string) (*structs.AppTokenResp, error) {
+	n := atomic.AddInt64(&f.count, 1)
+	if f.release != nil {
+		<-f.release
+	}
+	if v, _ := f.err.Load().(stubErr); v.err != nil {
+		return nil, v.err
+	}
+	ttl := f.ttl
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	return &structs.AppTokenResp{
+		AccessToken: "token" + string(rune('0'+n)),
+		ExpireTime:  utils.TimeMils(f.fc.Now()) + int64(ttl/time.Millisecond),
+		Namespace:   f.ns,
+	}, nil
+}
+
+func newStubCredential(f *stubTokenFetcher) *AppCredential {
+	c := NewAppCredential("id", "secret")
+	c.SetClock(f.fc)
+	c.fetch = f.fetch
+	return c
+}
+
+func TestAppCredentialSharedRefresh(t *testing.T) {
+	f := &stubTokenFetcher{fc: clock.NewFake(time.Unix(0, 0)), release: make(chan struct{})}
+	c := newStubCredential(f)
+	defer c.Close()
+
+	const n = 10
+	var wg sync.WaitGroup
+	tokens := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = c.getToken(context.Background())
+		}(i)
+	}
+	assert.Eventually(t, func() bool { return c.Stats().SharedWaits == n-1 }, time.Second, time.Millisecond)
+	close(f.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&f.count))
+	for _, token := range tokens {
+		assert.Equal(t, "token1", token)
+	}
+}
+
+func TestAppCredentialBackgroundRefresh(t *testing.T) {
+	f := &stubTokenFetcher{fc: clock.NewFake(time.Unix(0, 0))}
+	c := newStubCredential(f)
+	c.StartBackgroundRefresh()
+
+	token, err := c.getToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token1", token)
+	waitForWaiters(t, f.fc, 1)
+
+	// 在 RemainTime 与 Jitter 之前完成续期
+	f.fc.Advance(time.Hour - constants.AppTokenRefreshRemainTime*time.Millisecond)
+	assert.Eventually(t, func() bool { return c.Stats().Refreshes == 2 }, time.Second, time.Millisecond)
+	waitForWaiters(t, f.fc, 1)
+	token, err = c.getToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", token)
+
+	// 刷新失败时继续使用未过期的 token
+	f.err.Store(stubErr{errors.New("openapi unavailable")})
+	f.fc.Advance(time.Hour - constants.AppTokenRefreshRemainTime*time.Millisecond/2)
+	assert.Eventually(t, func() bool { return c.Stats().RefreshErrors >= 1 }, time.Second, time.Millisecond)
+	token, err = c.getToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", token)
+	stats := c.Stats()
+	assert.Equal(t, int64(1), stats.StaleServed)
+	assert.Equal(t, "openapi unavailable", stats.LastRefreshError)
+
+	// token 过期后返回错误
+	f.fc.Advance(constants.AppTokenRefreshRemainTime * time.Millisecond)
+	_, err = c.getToken(context.Background())
+	assert.Error(t, err)
+
+	c.Close()
+	assert.False(t, c.Stats().BackgroundRefresh)
+}
+
+func TestAppCredentialBackgroundRefreshShortLived(t *testing.T) {
+	f := &stubTokenFetcher{fc: clock.NewFake(time.Unix(0, 0)), ttl: 10 * time.Minute}
+	c := newStubCredential(f)
+	defer c.Close()
+	c.StartBackgroundRefresh()
+
+	// 有效期不超过 RemainTime 的 token 不会被立即反复刷新，剩余一半时刷新
+	_, err := c.getToken(context.Background())
+	assert.NoError(t, err)
+	waitForWaiters(t, f.fc, 1)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&f.count))
+	f.fc.Advance(5 * time.Minute)
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&f.count) == 2 }, time.Second, time.Millisecond)
+	waitForWaiters(t, f.fc, 1)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&f.count))
+
+	// 刷新失败时按 RetryInterval 指数退避
+	f.err.Store(stubErr{errors.New("openapi unavailable")})
+	f.fc.Advance(5 * time.Minute)
+	assert.Eventually(t, func() bool { return c.Stats().RefreshErrors == 1 }, time.Second, time.Millisecond)
+	waitForWaiters(t, f.fc, 1)
+	f.fc.Advance(constants.AppTokenRefreshRetryInterval * time.Millisecond)
+	assert.Eventually(t, func() bool { return c.Stats().RefreshErrors == 2 }, time.Second, time.Millisecond)
+	waitForWaiters(t, f.fc, 1)
+	f.fc.Advance(constants.AppTokenRefreshRetryInterval * time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int64(2), c.Stats().RefreshErrors)
+	f.fc.Advance(constants.AppTokenRefreshRetryInterval * time.Millisecond)
+	assert.Eventually(t, func() bool { return c.Stats().RefreshErrors == 3 }, time.Second, time.Millisecond)
+}
+
+func TestAppCredentialNoBackgroundRefreshByDefault(t *testing.T) {
+	f := &stubTokenFetcher{fc: clock.NewFake(time.Unix(0, 0))}
+	goroutines := runtime.NumGoroutine()
+
+	// 按请求创建的凭证用完即丢弃，不应留下后台刷新
+	func() {
+		c := newStubCredential(f)
+		token, err := c.getToken(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "token1", token)
+		assert.False(t, c.Stats().BackgroundRefresh)
+	}()
+	assert.Equal(t, 0, f.fc.Waiters())
+	f.fc.Advance(2 * time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&f.count))
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+
+	// 先获取 token 再开启后台刷新，立即启动
+	c := newStubCredential(f)
+	defer c.Close()
+	_, err := c.getToken(context.Background())
+	assert.NoError(t, err)
+	c.StartBackgroundRefresh()
+	waitForWaiters(t, f.fc, 1)
+	assert.True(t, c.Stats().BackgroundRefresh)
+}
+
+func TestHttpClientReplayOnAuthRejected(t *testing.T) {
+	f := &stubTokenFetcher{fc: clock.NewFake(time.Unix(0, 0))}
+	credential := newStubCredential(f)
+	defer credential.Close()
+
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"a":1}`, string(body))
+		switch r.Header.Get(constants.HttpHeaderKeyAuthorization) {
+		case "token1":
+			_, _ = w.Write([]byte(`{"code":"` + exp.ECTokenExpire + `"}`))
+		case "token2":
+			_, _ = w.Write([]byte(`{"code":"0"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	originLimiter := limiter
+	limiter = &RateLimiter{windowSize: time.Second, maxRequest: -1, requests: list.New(), clock: clock.NewFake(time.Unix(0, 0))}
+	defer func() { limiter = originLimiter }()
+
+	cli := &HttpClient{Type: OpenAPIClient}
+	ctx := SetCredentialToCtx(utils.SetPodRateLimitQuotaToCtx(context.Background(), 100), credential)
+	doPost := func() ([]byte, error) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte(`{"a":1}`)))
+		body, _, err := cli.doRequest(ctx, req, nil, nil, []ReqMiddleWare{AppTokenMiddleware})
+		return body, err
+	}
+
+	// token1 被拒绝后失效、刷新并重放一次
+	body, err := doPost()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"code":"0"}`, string(body))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+	assert.Equal(t, int64(1), credential.Stats().Invalidations)
+	assert.Equal(t, 1, limiter.requests.Len()) // 重放不重复占用限流配额
+
+	// 旧 token 不会失效已刷新的新 token
+	assert.False(t, credential.InvalidateToken("token1"))
+
+	// 重放后仍被拒绝时直接返回，不再重放
+	assert.True(t, credential.InvalidateToken("token2"))
+	_, err = doPost()
+	assert.Error(t, err)
+	assert.Equal(t, int64(4), atomic.LoadInt64(&calls))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&f.count))
+}
+
+func TestCredentialProviders(t *testing.T) {
+	ctx := context.Background()
+
+	// 文件 token 修改后重新加载
+	path := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(path, []byte("file-token1\n"), 0600))
+	fc := clock.NewFake(time.Unix(0, 0))
+	file := NewFileCredential(path)
+	file.SetClock(fc)
+	token, err := file.GetToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "file-token1", token)
+
+	assert.NoError(t, os.WriteFile(path, []byte("file-token2"), 0600))
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	token, _ = file.GetToken(ctx)
+	assert.Equal(t, "file-token1", token) // 未到检查间隔
+	fc.Advance(FileCredentialCheckInterval)
+	token, _ = file.GetToken(ctx)
+	assert.Equal(t, "file-token2", token)
+
+	// 链式凭证跳过失败的凭证，失效请求只影响持有该 token 的凭证
+	missing := NewFileCredential(filepath.Join(t.TempDir(), "missing"))
+	chain := NewChainCredential(missing, nil, file, NewStaticCredential("static-token"))
+	token, err = chain.GetToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "file-token2", token)
+	assert.False(t, chain.InvalidateToken("static-token"))
+	assert.True(t, chain.InvalidateToken("file-token2"))
+
+	_, err = NewChainCredential(missing, NewStaticCredential("")).GetToken(ctx)
+	assert.Error(t, err)
+
+	// 默认凭证在 ctx 未设置凭证时生效
+	SetDefaultCredential(NewStaticCredential("static-token"))
+	defer SetDefaultCredential(nil)
+	credential, err := resolveCredential(ctx)
+	assert.NoError(t, err)
+	token, _ = credential.GetToken(ctx)
+	assert.Equal(t, "static-token", token)
+	credential, _ = resolveCredential(SetCredentialToCtx(ctx, file))
+	assert.Equal(t, file, credential)
+}
+
+func TestCredentialRegistry(t *testing.T) {
+	r := NewCredentialRegistry(2)
+	defer r.Close()
+	fc := clock.NewFake(time.Unix(0, 0))
+	register := func(id, ns string) *AppCredential {
+		c := r.Get(id, "secret")
+		if c.clock != fc { // 新建的凭证替换为测试桩
+			f := &stubTokenFetcher{fc: fc, ns: ns}
+			c.SetClock(fc)
+			c.fetch = f.fetch
+		}
+		return c
+	}
+
+	app1 := register("app1", "ns1")
+	assert.Same(t, app1, register("app1", "ns1"))
+	tenant, err := r.GetTenantInfo(context.Background(), "app1", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "ns1", tenant.Namespace)
+	found, ok := r.LookupByNamespace("ns1")
+	assert.True(t, ok)
+	assert.Same(t, app1, found)
+
+	// secret 变化时重建凭证
+	assert.NotSame(t, app1, r.Get("app1", "secret2"))
+	_, ok = r.LookupByNamespace("ns1")
+	assert.False(t, ok)
+	r.Remove("app1")
+	assert.Equal(t, 0, r.Len())
+	register("app1", "ns1")
+
+	// 超出容量时淘汰最久未使用的应用
+	register("app2", "ns2")
+	_, ok = r.Lookup("app1")
+	assert.True(t, ok)
+	register("app3", "ns3")
+	assert.Equal(t, 2, r.Len())
+	_, ok = r.Lookup("app2")
+	assert.False(t, ok)
+	_, ok = r.Lookup("app1")
+	assert.True(t, ok)
+
+	ctx := r.WithCredential(context.Background(), "app3", "secret")
+	credential, err := resolveCredential(ctx)
+	assert.NoError(t, err)
+	assert.Same(t, r.Get("app3", "secret"), credential)
+}
+
+func TestAppCredentialTenantInfoRefresh(t *testing.T) {
+	f := &stubTokenFetcher{fc: clock.NewFake(time.Unix(0, 0)), ns: "ns1"}
+	c := newStubCredential(f)
+	defer c.Close()
+	c.SetTenantInfoTTL(10 * time.Minute)
+
+	var changes []string
+	c.OnTenantInfoChange(func(old, new *structs.Tenant) {
+		changes = append(changes, old.Namespace+"->"+new.Namespace)
+	})
+
+	tenant, err := c.GetTenantInfo(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ns1", tenant.Namespace)
+
+	// 有效期内使用缓存
+	f.ns = "ns2"
+	f.fc.Advance(5 * time.Minute)
+	tenant, _ = c.GetTenantInfo(context.Background())
+	assert.Equal(t, "ns1", tenant.Namespace)
+	assert.Empty(t, changes)
+
+	// 过期后随 token 一起刷新并通知变化
+	f.fc.Advance(5 * time.Minute)
+	tenant, _ = c.GetTenantInfo(context.Background())
+	assert.Equal(t, "ns2", tenant.Namespace)
+	assert.Equal(t, []string{"ns1->ns2"}, changes)
+
+	// 强制刷新，内容未变化时不通知
+	same, err := c.RefreshTenantInfo(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, tenant, same)
+	assert.Equal(t, 1, len(changes))
+
+	// 刷新失败时继续使用缓存
+	f.err.Store(stubErr{errors.New("openapi unavailable")})
+	f.fc.Advance(10 * time.Minute)
+	tenant, err = c.GetTenantInfo(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ns2", tenant.Namespace)
+}
+
+func TestServiceFallbackCredential(t *testing.T) {
+	fc := clock.NewFake(time.Unix(0, 0))
+	serviceFetcher := &stubTokenFetcher{fc: fc}
+	serviceFetcher.err.Store(stubErr{errors.New("faasinfra unavailable")})
+	service := newStubCredential(serviceFetcher)
+	service.serviceToken = true
+	defer service.Close()
+	appFetcher := &stubTokenFetcher{fc: fc}
+	app := newStubCredential(appFetcher)
+	defer app.Close()
+	credential := &serviceFallbackCredential{service: service, fallback: app}
+
+	// service token 获取失败时回退到 app token，重试间隔内不再尝试
+	token, err := credential.GetToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token1", token)
+	_, _ = credential.GetToken(context.Background())
+	assert.Equal(t, int64(1), atomic.LoadInt64(&serviceFetcher.count))
+
+	// 重试间隔后恢复使用 service token，且不写入租户信息
+	serviceFetcher.err.Store(stubErr{})
+	fc.Advance(constants.AppTokenRefreshRetryInterval * time.Millisecond)
+	token, err = credential.GetToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", token)
+	_, ok := service.tenantInfo.Load().(*structs.Tenant)
+	assert.False(t, ok)
+	assert.True(t, credential.InvalidateToken("token2"))
+
+	assert.Equal(t, FaaSInfraClient, getClientTypeFromCtx(withClientType(context.Background(), FaaSInfraClient)))
+}
+
+func TestServiceFallbackCredentialUnsupported(t *testing.T) {
+	fc := clock.NewFake(time.Unix(0, 0))
+	serviceFetcher := &stubTokenFetcher{fc: fc}
+	serviceFetcher.err.Store(stubErr{errServiceTokenUnsupported})
+	service := newStubCredential(serviceFetcher)
+	service.serviceToken = true
+	defer service.Close()
+	app := newStubCredential(&stubTokenFetcher{fc: fc})
+	defer app.Close()
+	credential := &serviceFallbackCredential{service: service, fallback: app}
+
+	// 接口不存在时仅尝试一次，之后当前进程始终使用 app token
+	token, err := credential.GetToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token1", token)
+	for i := 0; i < 3; i++ {
+		fc.Advance(constants.AppTokenRefreshRetryInterval * time.Millisecond)
+		token, err = credential.GetToken(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "token1", token)
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&serviceFetcher.count))
+
+	assert.True(t, hasStatusCode(map[string]interface{}{extraKeyStatusCode: http.StatusNotFound}))
+	assert.True(t, hasStatusCode(map[string]interface{}{extraKeyStatusCode: http.StatusInternalServerError}))
+	assert.False(t, hasStatusCode(nil))
+
+	// 默认不开启 service token，不发送 clientSecret
+	_, err = GetServiceTokenHttp(context.Background(), "id", "secret")
+	assert.Equal(t, errServiceTokenDisabled, err)
+}
+
+func TestNonRetryableError(t *testing.T) {
+	assert.False(t, IsRetryableError(nil))
+	assert.True(t, IsRetryableError(errors.New("timeout")))
+	err := NonRetryableError(exp.NewErrWithCodeV2("k_op_ec_10001", "invalid param", "log1"))
+	assert.False(t, IsRetryableError(err))
+	assert.Contains(t, err.Error(), "invalid param")
+	assert.Nil(t, NonRetryableError(nil))
+
+	assert.True(t, isClientErrorStatus(map[string]interface{}{extraKeyStatusCode: http.StatusForbidden}))
+	assert.False(t, isClientErrorStatus(map[string]interface{}{extraKeyStatusCode: http.StatusTooManyRequests}))
+	assert.False(t, isClientErrorStatus(map[string]interface{}{extraKeyStatusCode: http.StatusBadGateway}))
+	assert.False(t, isClientErrorStatus(nil))
+}
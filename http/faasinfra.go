@@ -5,20 +5,88 @@ package http
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
 
 	"github.com/tidwall/gjson"
 
 	"github.com/byted-apaas/server-common-go/constants"
 	exp "github.com/byted-apaas/server-common-go/exceptions"
+	"github.com/byted-apaas/server-common-go/structs"
 	"github.com/byted-apaas/server-common-go/utils"
 )
 
+// errServiceTokenUnsupported FaaSInfra 不支持 service token 接口，当前进程不再请求
+var errServiceTokenUnsupported = exp.InternalError("[ServiceCredential] FaaSInfra does not support %s", FaaSInfraPathGetServiceToken)
+
+// errServiceTokenDisabled 未开启 service token
+var errServiceTokenDisabled = exp.InternalError("[ServiceCredential] service token is disabled")
+
+// extraKeyStatusCode 请求失败时 extra 中记录的 HTTP 状态码
+const extraKeyStatusCode = "statusCode"
+
+var serviceTokenEnabled int32
+
+// SetServiceTokenEnabled 开启或关闭 FaaSInfra service token 鉴权，默认关闭，FaaSInfra 请求使用 app token。
+// 开启后会将 clientID/clientSecret 发送至 FaaSInfraPathGetServiceToken，需确认 FaaSInfra 已提供该接口
+func SetServiceTokenEnabled(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&serviceTokenEnabled, v)
+}
+
+func isServiceTokenEnabled() bool {
+	return atomic.LoadInt32(&serviceTokenEnabled) == 1
+}
+
+// hasStatusCode 请求得到了非 2xx 响应
+func hasStatusCode(extra map[string]interface{}) bool {
+	_, ok := extra[extraKeyStatusCode]
+	return ok
+}
+
+// GetServiceTokenHttp 使用 clientID/clientSecret 从 FaaSInfra 换取 service token，不依赖 OpenAPI，需通过 SetServiceTokenEnabled 开启。
+// 接口返回非 2xx 响应时视为不支持，返回 errServiceTokenUnsupported
+func GetServiceTokenHttp(ctx context.Context, clientID, clientSecret string) (*structs.ServiceTokenResp, error) {
+	if !isServiceTokenEnabled() {
+		return nil, errServiceTokenDisabled
+	}
+	ctx = utils.SetApiTimeoutMethodToCtx(ctx, constants.GetServiceToken)
+	ctx = utils.SetRequestPriorityToCtx(ctx, utils.RequestPriorityInfrastructure)
+	data := map[string]interface{}{
+		"clientId":     clientID,
+		"clientSecret": clientSecret,
+	}
+
+	body, extra, err := GetFaaSInfraClient(ctx).PostJson(ctx, FaaSInfraPathGetServiceToken, nil, data)
+	if err != nil && hasStatusCode(extra) {
+		return nil, errServiceTokenUnsupported
+	}
+	body, err = utils.ErrorWrapper(body, extra, err)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenResult := structs.ServiceTokenResp{}
+	if err = utils.JsonUnmarshalBytes(body, &tokenResult); err != nil {
+		return nil, exp.InternalError("[ServiceCredential] fetchToken Unmarshal TokenResult failed, err: %v", err)
+	}
+	return &tokenResult, nil
+}
+
 func SendLog(ctx context.Context, data interface{}) error {
 	ctx = utils.SetApiTimeoutMethodToCtx(ctx, constants.SendLog)
+	ctx = utils.SetRequestPriorityToCtx(ctx, utils.RequestPriorityInfrastructure)
 	body, extra, err := GetFaaSInfraClient(ctx).PostJson(ctx, GetFaaSInfraPathSendLog(), map[string][]string{
 		"Kldx-Version": {"4.0.0"}, // TODO FaaSInfra 后续下掉
 	}, data, AppTokenMiddleware, TenantAndUserMiddleware, ServiceIDMiddleware)
 	if err != nil {
+		if isClientErrorStatus(extra) {
+			return NonRetryableError(err)
+		}
 		return exp.ErrWrap(err)
 	}
 
@@ -27,5 +95,35 @@ func SendLog(ctx context.Context, data interface{}) error {
 	if code == exp.SCSuccess {
 		return nil
 	}
-	return exp.NewErrWithCodeV2(code, msg, utils.GetLogIDFromExtra(extra))
+	// 业务错误（如参数非法、鉴权失败）重试无意义
+	return NonRetryableError(exp.NewErrWithCodeV2(code, msg, utils.GetLogIDFromExtra(extra)))
+}
+
+// nonRetryableError 不可重试的请求错误，如 4xx、鉴权失败与业务错误
+type nonRetryableError struct {
+	*exp.BaseError
+}
+
+// NonRetryableError 将 err 标记为不可重试
+func NonRetryableError(err error) error {
+	if err == nil {
+		return nil
+	}
+	baseErr, ok := err.(*exp.BaseError)
+	if !ok {
+		baseErr = exp.ErrWrap(err)
+	}
+	return &nonRetryableError{BaseError: baseErr}
+}
+
+// IsRetryableError 判断请求错误是否为可重试的临时错误，如网络错误、超时、429 与 5xx
+func IsRetryableError(err error) bool {
+	var e *nonRetryableError
+	return err != nil && !errors.As(err, &e)
+}
+
+// isClientErrorStatus 请求因客户端错误失败（4xx，408 与 429 除外）
+func isClientErrorStatus(extra map[string]interface{}) bool {
+	status, ok := extra[extraKeyStatusCode].(int)
+	return ok && status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
 }
@@ -139,6 +139,8 @@ func (c *HttpClient) doRequest(ctx context.Context, req *http.Request, headers m
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	// 标记请求的 client 类型，中间件据此选择凭证
+	ctx = withClientType(ctx, c.Type)
 
 	// 限流控制
 	err := c.checkPodRateLimit(ctx)
@@ -147,13 +149,36 @@ func (c *HttpClient) doRequest(ctx context.Context, req *http.Request, headers m
 	}
 
 	// 反压降速控制
-	checkPressureAndDecelerate(ctx)
+	if err = checkPressureAndDecelerate(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	// 保留未经中间件修改的请求，token 被服务端拒绝时用于重放
+	replayReq := cloneRequest(ctx, req)
+
+	respBody, extra, authRejected, err := c.doRequestOnce(ctx, req, headers, reqBody, midList)
+	if !authRejected || replayReq == nil || checkAuthReplayTag(ctx) {
+		return respBody, extra, err
+	}
+
+	// token 被拒绝：失效当前 token 后重新执行中间件获取新 token 并重放一次，重放不再占用限流与降速
+	credential, credErr := resolveCredential(ctx)
+	if credErr != nil || credential == nil {
+		return respBody, extra, err
+	}
+	credential.InvalidateToken(req.Header.Get(constants.HttpHeaderKeyAuthorization))
+	respBody, extra, _, err = c.doRequestOnce(withAuthReplayTag(ctx), replayReq, headers, reqBody, midList)
+	return respBody, extra, err
+}
 
+// doRequestOnce 执行中间件并发送一次请求，authRejected 表示服务端因 token 无效拒绝了请求
+func (c *HttpClient) doRequestOnce(ctx context.Context, req *http.Request, headers map[string][]string, reqBody []byte, midList []ReqMiddleWare) ([]byte, map[string]interface{}, bool, error) {
+	var err error
 	// 执行中间件
 	for _, mid := range midList {
 		err = mid(ctx, req)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 	}
 
@@ -180,7 +205,7 @@ func (c *HttpClient) doRequest(ctx context.Context, req *http.Request, headers m
 	if isUseMesh {
 		req, err = c.transferToMeshReq(ctx, req, psm, cluster)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 	}
 
@@ -208,10 +233,10 @@ func (c *HttpClient) doRequest(ctx context.Context, req *http.Request, headers m
 		return nil
 	})
 	if err != nil {
-		return nil, nil, exp.InternalError("doRequest failed, err: %v, logid: %v", err, utils.GetLogIDFromCtx(ctx))
+		return nil, nil, false, exp.InternalError("doRequest failed, err: %v, logid: %v", err, utils.GetLogIDFromCtx(ctx))
 	}
 	if resp == nil {
-		return nil, nil, exp.InternalError("doRequest failed, resp is nil, logid: %v", utils.GetLogIDFromCtx(ctx))
+		return nil, nil, false, exp.InternalError("doRequest failed, resp is nil, logid: %v", utils.GetLogIDFromCtx(ctx))
 	}
 
 	extra, ctx := c.extractResponseInfo(ctx, resp)
@@ -222,14 +247,55 @@ func (c *HttpClient) doRequest(ctx context.Context, req *http.Request, headers m
 
 	respBody, err = io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, extra, exp.InternalError("doRequest readBody failed, err: %v, logid: %v", err, utils.GetLogIDFromCtx(ctx))
+		return nil, extra, false, exp.InternalError("doRequest readBody failed, err: %v, logid: %v", err, utils.GetLogIDFromCtx(ctx))
 	}
 
+	authRejected := req.Header.Get(constants.HttpHeaderKeyAuthorization) != "" && isAuthRejected(resp.StatusCode, respBody)
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, extra, exp.InternalError("doRequest failed, statusCode is %d, logid: %v, respBody: %s", resp.StatusCode, utils.GetLogIDFromCtx(ctx), string(respBody))
+		extra[extraKeyStatusCode] = resp.StatusCode
+		return nil, extra, authRejected, exp.InternalError("doRequest failed, statusCode is %d, logid: %v, respBody: %s", resp.StatusCode, utils.GetLogIDFromCtx(ctx), string(respBody))
+	}
+
+	return respBody, extra, authRejected, nil
+}
+
+// isAuthRejected 判断服务端是否因 token 过期、非法或缺失拒绝了请求
+func isAuthRejected(statusCode int, respBody []byte) bool {
+	if statusCode == http.StatusUnauthorized {
+		return true
+	}
+	switch gjson.GetBytes(respBody, "code").String() {
+	case exp.ECTokenExpire, exp.ECIllegalToken, exp.ECMissingToken, exp.FaaSInfraFailCodeMissingToken:
+		return true
+	default:
+		return false
+	}
+}
+
+// cloneRequest 复制请求，body 无法重新读取时返回 nil
+func cloneRequest(ctx context.Context, req *http.Request) *http.Request {
+	cloned := req.Clone(ctx)
+	if req.Body == nil || req.Body == http.NoBody {
+		return cloned
+	}
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
 	}
+	cloned.Body = body
+	return cloned
+}
 
-	return respBody, extra, nil
+func withAuthReplayTag(ctx context.Context) context.Context {
+	return context.WithValue(ctx, constants.CtxKeyAuthReplayTag, true)
+}
+
+func checkAuthReplayTag(ctx context.Context) bool {
+	return ctx.Value(constants.CtxKeyAuthReplayTag) != nil
 }
 
 func (c *HttpClient) Get(ctx context.Context, path string, headers map[string][]string, midList ...ReqMiddleWare) ([]byte, map[string]interface{}, error) {
@@ -382,6 +448,15 @@ func (c *HttpClient) extractResponseInfo(ctx context.Context, resp *http.Respons
 		logID := resp.Header.Get(constants.HttpHeaderKeyLogID)
 		extra[constants.HttpHeaderKeyLogID] = logID
 		ctx = utils.SetLogIDToCtx(ctx, logID)
+
+		// 响应头推送的压力信号，立即更新降速缓存
+		if signal := resp.Header.Get(constants.PressureSignalHeader); signal != "" {
+			if signals, err := parsePressureSignals(signal); err != nil {
+				logPressureEvent(ctx, utils.LogLevelWarn, &pressureEvent{Event: pressureEventSignalParseError, Keys: []string{constants.PressureSignalHeader}, Error: err.Error()})
+			} else {
+				UpdatePressureSignals(signals)
+			}
+		}
 	}
 
 	return extra, ctx
@@ -425,7 +500,8 @@ func (c *HttpClient) checkPodRateLimit(ctx context.Context) error {
 	}
 
 	// 未触发限流，请求放行
-	if limiter.AllowRequest() {
+	priority := utils.GetRequestPriorityFromCtx(ctx)
+	if limiter.AllowRequestWithPriority(priority) {
 		return nil
 	}
 
@@ -483,6 +559,7 @@ func (c *HttpClient) logRequest(ctx context.Context, req *http.Request, resp *ht
 	if utils.GetSDKCallLogDetailSwitchFromCtx(ctx) {
 		isFileTransfer := isFileTransferRequest(req)
 		isTokenSuccess := isTokenRequest(req) && isTokenRequestSuccess(statusCode, respBody)
+		redactor := utils.GetRedactorFromCtx(ctx)
 
 		var sb strings.Builder
 		sb.WriteString(utils.GetFormatDate())
@@ -497,7 +574,7 @@ func (c *HttpClient) logRequest(ctx context.Context, req *http.Request, resp *ht
 		sb.WriteString("\n🍏request header:")
 		sb.WriteString(formatHeaderSafe(req.Header))
 		sb.WriteString("\n🍏request body:")
-		sb.WriteString(formatBodySafe(reqBody, isFileTransfer, isTokenSuccess))
+		sb.WriteString(formatBodySafe(reqBody, isFileTransfer, isTokenSuccess, redactor))
 		sb.WriteString("\n🍎response header:")
 		respHeader := ""
 		if resp != nil {
@@ -505,25 +582,30 @@ func (c *HttpClient) logRequest(ctx context.Context, req *http.Request, resp *ht
 		}
 		sb.WriteString(respHeader)
 		sb.WriteString("\n🍎response body:")
-		sb.WriteString(formatBodySafe(respBody, isFileTransfer, isTokenSuccess))
+		sb.WriteString(formatBodySafe(respBody, isFileTransfer, isTokenSuccess, redactor))
 		fmt.Println(sb.String())
 	}
 }
 
-func checkPressureAndDecelerate(ctx context.Context) {
+func checkPressureAndDecelerate(ctx context.Context) error {
 	// 反压信号检测请求，不进行降速
 	if checkPressureSdkReqTag(ctx) {
-		return
+		return nil
+	}
+
+	// 关键请求与基础设施请求（获取 token、上报日志等）不进行降速
+	if priority := utils.GetRequestPriorityFromCtx(ctx); priority == utils.RequestPriorityCritical || priority == utils.RequestPriorityInfrastructure {
+		return nil
 	}
 
 	// pressureDecelerator 需要在 webframe 请求进入前调用 InitPressureDecelerator 方法进行初始化，否则无法降速
 	if pressureDecelerator == nil {
-		return
+		return nil
 	}
 
 	// 反压降速开关未开启，不进行降速
 	if !utils.GetPressureNeedDecelerateFromCtx(ctx) {
-		return
+		return nil
 	}
 
 	// 反压降速检测
@@ -532,20 +614,26 @@ func checkPressureAndDecelerate(ctx context.Context) {
 
 	// 未触发降速
 	if sleepTime <= 0 {
-		return
+		return nil
 	}
 
+	// 执行降速
+	slept, err := pressureDecelerator.Decelerate(ctx, key, sleepTime)
+
 	// 记录降速日志
 	msg := utils.SpeedDownMessage{
-		Key:       key,
-		SleepTime: sleepTime,
+		Key:             key,
+		SleepTime:       sleepTime,
+		ActualSleepTime: slept.Milliseconds(),
+	}
+	if err != nil {
+		msg.Error = err.Error()
 	}
 	msgBytes, _ := json.Marshal(msg)
 	speedDownLog := utils.NewFormatLog(ctx, utils.LogLevelWarn, constants.SpeedDownLogType, string(msgBytes))
 	fmt.Println(speedDownLog.String())
 
-	// 执行降速
-	time.Sleep(time.Duration(sleepTime) * time.Millisecond)
+	return err
 }
 
 func GetTimeoutCtx(ctx context.Context) (context.Context, context.CancelFunc) {
@@ -663,8 +751,8 @@ func formatHeaderSafe(header http.Header) string {
 	return format.Any(safeHeader)
 }
 
-// formatBodySafe 安全地格式化 body，对于大 body 直接返回摘要信息避免 OOM
-func formatBodySafe(v interface{}, isFileTransfer, isToken bool) string {
+// formatBodySafe 安全地格式化 body，对于大 body 直接返回摘要信息避免 OOM，redactor 非 nil 时对内容脱敏
+func formatBodySafe(v interface{}, isFileTransfer, isToken bool, redactor *utils.Redactor) string {
 	if v == nil {
 		return ""
 	}
@@ -688,7 +776,7 @@ func formatBodySafe(v interface{}, isFileTransfer, isToken bool) string {
 		}
 	}
 
-	str := format.Any(v)
+	str := redactor.Redact(format.Any(v))
 	if len(str) > MaxSize {
 		return str[:MaxSize] + fmt.Sprintf(">>> 🥥 truncated(%d) > 10KB", len(str))
 	}
@@ -11,9 +11,15 @@ import (
 )
 
 const (
-	OpenapiPathGetToken  = "/auth/v1/appToken"
-	FaaSInfraPathSendLog = "/log/v1/namespaces/:namespace/logs/batchSend"
-	InnerAPIGetFunction  = "/cloudfunction/v1/namespaces/:namespace/functions/detail"
+	OpenapiPathGetToken          = "/auth/v1/appToken"
+	FaaSInfraPathSendLog         = "/log/v1/namespaces/:namespace/logs/batchSend"
+	FaaSInfraPathGetServiceToken = "/auth/v1/serviceToken"
+	InnerAPIGetFunction          = "/cloudfunction/v1/namespaces/:namespace/functions/detail"
+
+	OpenapiPathDefaultIntegrationAppAccessToken    = "/api/integration/v1/namespaces/:namespace/defaultLark/appAccessToken"
+	OpenapiPathDefaultIntegrationTenantAccessToken = "/api/integration/v1/namespaces/:namespace/defaultLark/tenantAccessToken"
+	OpenapiPathIntegrationAppAccessToken           = "/api/integration/v1/namespaces/:namespace/lark/appAccessToken/:apiName"
+	OpenapiPathIntegrationTenantAccessToken        = "/api/integration/v1/namespaces/:namespace/lark/tenantAccessToken/:apiName"
 )
 
 func GetFaaSInfraPathSendLog() string {
@@ -23,3 +29,19 @@ func GetFaaSInfraPathSendLog() string {
 func GetInnerAPIPathGetFunction() string {
 	return strings.ReplaceAll(InnerAPIGetFunction, constants.ReplaceNamespace, utils.GetNamespace())
 }
+
+func GetOpenapiPathIntegrationAppAccessToken(apiName string) string {
+	if apiName == "" {
+		return strings.ReplaceAll(OpenapiPathDefaultIntegrationAppAccessToken, constants.ReplaceNamespace, utils.GetNamespace())
+	}
+	path := strings.ReplaceAll(OpenapiPathIntegrationAppAccessToken, constants.ReplaceNamespace, utils.GetNamespace())
+	return strings.ReplaceAll(path, constants.ReplaceAPIName, apiName)
+}
+
+func GetOpenapiPathIntegrationTenantAccessToken(apiName string) string {
+	if apiName == "" {
+		return strings.ReplaceAll(OpenapiPathDefaultIntegrationTenantAccessToken, constants.ReplaceNamespace, utils.GetNamespace())
+	}
+	path := strings.ReplaceAll(OpenapiPathIntegrationTenantAccessToken, constants.ReplaceNamespace, utils.GetNamespace())
+	return strings.ReplaceAll(path, constants.ReplaceAPIName, apiName)
+}
@@ -0,0 +1,284 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package http
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/byted-apaas/server-common-go/constants"
+	cExceptions "github.com/byted-apaas/server-common-go/exceptions"
+	"github.com/byted-apaas/server-common-go/structs"
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+// GetIntegrationAppAccessTokenHttp 获取飞书集成 app_access_token，apiName 为空时获取默认飞书集成
+func GetIntegrationAppAccessTokenHttp(ctx context.Context, apiName string) (*structs.AppAccessToken, error) {
+	method := constants.GetIntegrationAppAccessToken
+	if apiName == "" {
+		method = constants.GetDefaultIntegrationAppAccessToken
+	}
+	ctx = utils.SetApiTimeoutMethodToCtx(ctx, method)
+
+	data, err := utils.ErrorWrapper(GetOpenapiClient().PostJson(ctx, GetOpenapiPathIntegrationAppAccessToken(apiName), nil, map[string]interface{}{}, AppTokenMiddleware, TenantAndUserMiddleware, ServiceIDMiddleware))
+	if err != nil {
+		return nil, err
+	}
+
+	token := structs.AppAccessToken{}
+	if err = utils.JsonUnmarshalBytes(data, &token); err != nil {
+		return nil, cExceptions.InternalError("GetIntegrationAppAccessToken Unmarshal failed, err: %v", err)
+	}
+	return &token, nil
+}
+
+// GetIntegrationTenantAccessTokenHttp 获取飞书集成 tenant_access_token，apiName 为空时获取默认飞书集成
+func GetIntegrationTenantAccessTokenHttp(ctx context.Context, apiName string) (*structs.TenantAccessToken, error) {
+	method := constants.GetIntegrationTenantAccessToken
+	if apiName == "" {
+		method = constants.GetDefaultIntegrationTenantAccessToken
+	}
+	ctx = utils.SetApiTimeoutMethodToCtx(ctx, method)
+
+	data, err := utils.ErrorWrapper(GetOpenapiClient().PostJson(ctx, GetOpenapiPathIntegrationTenantAccessToken(apiName), nil, map[string]interface{}{}, AppTokenMiddleware, TenantAndUserMiddleware, ServiceIDMiddleware))
+	if err != nil {
+		return nil, err
+	}
+
+	token := structs.TenantAccessToken{}
+	if err = utils.JsonUnmarshalBytes(data, &token); err != nil {
+		return nil, cExceptions.InternalError("GetIntegrationTenantAccessToken Unmarshal failed, err: %v", err)
+	}
+	return &token, nil
+}
+
+// IntegrationTokenCache 飞书集成 token 缓存，按凭证身份、token 类型与集成 apiName 缓存，剩余有效期不足时刷新。
+// 缓存按 LRU 淘汰，容量上限为 constants.IntegrationTokenCacheCapacity，已过期的 token 在插入新 key 时清理
+type IntegrationTokenCache struct {
+	clock    clock.Clock
+	capacity int
+
+	lock    sync.Mutex
+	entries map[integrationTokenKey]*list.Element // value: *integrationTokenEntry
+	lru     *list.List                            // 头部为最近使用
+
+	fetchApp    func(ctx context.Context, apiName string) (*structs.AppAccessToken, error)
+	fetchTenant func(ctx context.Context, apiName string) (*structs.TenantAccessToken, error)
+}
+
+type integrationTokenKey struct {
+	clientID string // ctx 中凭证的身份标识，空表示默认凭证
+	tenant   bool   // true: tenant_access_token，false: app_access_token
+	apiName  string
+}
+
+type integrationTokenEntry struct {
+	key        integrationTokenKey
+	lock       sync.Mutex // 保护 value 与 inflight，不在获取 token 期间持有
+	value      interface{}
+	expireTime int64                 // 单位：ms，原子读写，0 表示尚未获取到 token
+	inflight   *integrationFetchCall // 进行中的获取，并发调用方共享同一次获取
+}
+
+// integrationFetchCall 一次进行中的 token 获取
+type integrationFetchCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func NewIntegrationTokenCache() *IntegrationTokenCache {
+	return &IntegrationTokenCache{
+		clock:       clock.Default(),
+		capacity:    constants.IntegrationTokenCacheCapacity,
+		entries:     make(map[integrationTokenKey]*list.Element),
+		lru:         list.New(),
+		fetchApp:    GetIntegrationAppAccessTokenHttp,
+		fetchTenant: GetIntegrationTenantAccessTokenHttp,
+	}
+}
+
+// SetClock 替换时钟，用于测试 token 过期
+func (c *IntegrationTokenCache) SetClock(clk clock.Clock) {
+	c.clock = clock.OrDefault(clk)
+}
+
+func (c *IntegrationTokenCache) nowMils() int64 {
+	return utils.TimeMils(clock.OrDefault(c.clock).Now())
+}
+
+// GetAppAccessToken 获取飞书集成 app_access_token，apiName 为空时获取默认飞书集成
+func (c *IntegrationTokenCache) GetAppAccessToken(ctx context.Context, apiName string) (*structs.AppAccessToken, error) {
+	value, err := c.get(ctx, false, apiName, func() (interface{}, int64, error) {
+		token, err := c.fetchApp(ctx, apiName)
+		if err != nil {
+			return nil, 0, err
+		}
+		return token, token.Expire, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*structs.AppAccessToken), nil
+}
+
+// GetTenantAccessToken 获取飞书集成 tenant_access_token，apiName 为空时获取默认飞书集成
+func (c *IntegrationTokenCache) GetTenantAccessToken(ctx context.Context, apiName string) (*structs.TenantAccessToken, error) {
+	value, err := c.get(ctx, true, apiName, func() (interface{}, int64, error) {
+		token, err := c.fetchTenant(ctx, apiName)
+		if err != nil {
+			return nil, 0, err
+		}
+		return token, token.Expire, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*structs.TenantAccessToken), nil
+}
+
+// Clear 清空缓存
+func (c *IntegrationTokenCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[integrationTokenKey]*list.Element)
+	c.lru.Init()
+}
+
+// Len 当前缓存的 key 数量
+func (c *IntegrationTokenCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func (c *IntegrationTokenCache) get(ctx context.Context, tenant bool, apiName string, fetch func() (interface{}, int64, error)) (interface{}, error) {
+	clientID, ok := credentialIdentity(getCredentialFromCtx(ctx))
+	if !ok {
+		// 凭证无法提供稳定身份时不缓存，避免不同凭证共用 token
+		value, _, err := fetch()
+		return value, err
+	}
+	entry := c.entry(integrationTokenKey{clientID: clientID, tenant: tenant, apiName: apiName})
+
+	entry.lock.Lock()
+	expireTime, old := atomic.LoadInt64(&entry.expireTime), entry.value
+	if old != nil && expireTime-c.nowMils() > constants.IntegrationTokenRefreshRemainTime {
+		entry.lock.Unlock()
+		return old, nil
+	}
+	if call := entry.inflight; call != nil {
+		entry.lock.Unlock()
+		// 其他请求刷新中，旧 token 尚未过期时直接使用，否则等待刷新结果
+		if old != nil && expireTime > c.nowMils() {
+			return old, nil
+		}
+		select {
+		case <-call.done:
+			return call.value, call.err
+		case <-ctx.Done():
+			return nil, cExceptions.InternalError("[IntegrationTokenCache] wait for token refresh failed: %v", ctx.Err())
+		}
+	}
+	call := &integrationFetchCall{done: make(chan struct{})}
+	entry.inflight = call
+	entry.lock.Unlock()
+
+	value, expire, err := fetch()
+
+	entry.lock.Lock()
+	entry.inflight = nil
+	switch {
+	case err == nil:
+		entry.value, call.value = value, value
+		atomic.StoreInt64(&entry.expireTime, integrationExpireTime(expire))
+	case old != nil && expireTime > c.nowMils(): // 刷新失败但旧 token 尚未过期，继续使用旧 token
+		call.value = old
+	default:
+		call.err = err
+	}
+	entry.lock.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+// entry 获取 key 对应的缓存项并标记为最近使用，新建时清理已过期的项并按容量淘汰最久未使用的项
+func (c *IntegrationTokenCache) entry(key integrationTokenKey) *integrationTokenEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*integrationTokenEntry)
+	}
+
+	now := c.nowMils()
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		expireTime := atomic.LoadInt64(&elem.Value.(*integrationTokenEntry).expireTime)
+		if expireTime > 0 && expireTime <= now {
+			c.removeElement(elem)
+		}
+		elem = prev
+	}
+	for c.capacity > 0 && c.lru.Len() >= c.capacity {
+		c.removeElement(c.lru.Back())
+	}
+
+	entry := &integrationTokenEntry{key: key}
+	c.entries[key] = c.lru.PushFront(entry)
+	return entry
+}
+
+func (c *IntegrationTokenCache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*integrationTokenEntry).key)
+}
+
+// integrationExpireTime 将接口返回的 expire 转换为过期时间戳（ms），接口约定 expire 为过期时间戳，单位：s
+func integrationExpireTime(expire int64) int64 {
+	return expire * 1000
+}
+
+var defaultIntegrationTokenCache = NewIntegrationTokenCache()
+
+// SetIntegrationTokenCacheToCtx 设置 ctx 使用的飞书集成 token 缓存
+func SetIntegrationTokenCacheToCtx(ctx context.Context, cache *IntegrationTokenCache) context.Context {
+	if cache == nil {
+		return ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, constants.CtxKeyIntegrationToken, cache)
+}
+
+// GetIntegrationTokenCacheFromCtx 获取 ctx 使用的飞书集成 token 缓存，未设置时返回全局缓存
+func GetIntegrationTokenCacheFromCtx(ctx context.Context) *IntegrationTokenCache {
+	if ctx != nil {
+		if cache, ok := ctx.Value(constants.CtxKeyIntegrationToken).(*IntegrationTokenCache); ok && cache != nil {
+			return cache
+		}
+	}
+	return defaultIntegrationTokenCache
+}
+
+// GetIntegrationAppAccessToken 使用 ctx 中的缓存获取飞书集成 app_access_token，apiName 为空时获取默认飞书集成
+func GetIntegrationAppAccessToken(ctx context.Context, apiName string) (*structs.AppAccessToken, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return GetIntegrationTokenCacheFromCtx(ctx).GetAppAccessToken(ctx, apiName)
+}
+
+// GetIntegrationTenantAccessToken 使用 ctx 中的缓存获取飞书集成 tenant_access_token，apiName 为空时获取默认飞书集成
+func GetIntegrationTenantAccessToken(ctx context.Context, apiName string) (*structs.TenantAccessToken, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return GetIntegrationTokenCacheFromCtx(ctx).GetTenantAccessToken(ctx, apiName)
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/byted-apaas/server-common-go/structs"
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+func TestIntegrationTokenCache(t *testing.T) {
+	fc := clock.NewFake(time.Unix(1700000000, 0))
+	cache := NewIntegrationTokenCache()
+	cache.SetClock(fc)
+
+	var appCount, tenantCount int64
+	var fetchErr atomic.Value
+	cache.fetchApp = func(ctx context.Context, apiName string) (*structs.AppAccessToken, error) {
+		n := atomic.AddInt64(&appCount, 1)
+		return &structs.AppAccessToken{AppAccessToken: apiName + "-app-" + string(rune('0'+n)), Expire: fc.Now().Unix() + 7200}, nil
+	}
+	cache.fetchTenant = func(ctx context.Context, apiName string) (*structs.TenantAccessToken, error) {
+		if err, _ := fetchErr.Load().(error); err != nil {
+			return nil, err
+		}
+		n := atomic.AddInt64(&tenantCount, 1)
+		return &structs.TenantAccessToken{TenantAccessToken: apiName + "-tenant-" + string(rune('0'+n)), Expire: fc.Now().Unix() + 7200}, nil
+	}
+	ctx := SetIntegrationTokenCacheToCtx(context.Background(), cache)
+	assert.Same(t, cache, GetIntegrationTokenCacheFromCtx(ctx))
+
+	// 有效期内使用缓存，不同集成分别缓存
+	app, err := GetIntegrationAppAccessToken(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "-app-1", app.AppAccessToken)
+	app, _ = GetIntegrationAppAccessToken(ctx, "")
+	assert.Equal(t, "-app-1", app.AppAccessToken)
+	app, _ = GetIntegrationAppAccessToken(ctx, "lark1")
+	assert.Equal(t, "lark1-app-2", app.AppAccessToken)
+	tenant, err := GetIntegrationTenantAccessToken(ctx, "lark1")
+	assert.NoError(t, err)
+	assert.Equal(t, "lark1-tenant-1", tenant.TenantAccessToken)
+
+	// 剩余有效期不足时刷新，刷新失败时继续使用未过期的 token
+	fc.Advance(time.Hour + 55*time.Minute)
+	fetchErr.Store(errors.New("integration unavailable"))
+	tenant, err = GetIntegrationTenantAccessToken(ctx, "lark1")
+	assert.NoError(t, err)
+	assert.Equal(t, "lark1-tenant-1", tenant.TenantAccessToken)
+	fc.Advance(5 * time.Minute)
+	_, err = GetIntegrationTenantAccessToken(ctx, "lark1")
+	assert.Error(t, err)
+
+	app, _ = GetIntegrationAppAccessToken(ctx, "")
+	assert.Equal(t, "-app-3", app.AppAccessToken)
+}
+
+func TestIntegrationTokenCacheSingleflight(t *testing.T) {
+	cache := NewIntegrationTokenCache()
+	var count int64
+	started, release := make(chan struct{}), make(chan struct{})
+	cache.fetchApp = func(ctx context.Context, apiName string) (*structs.AppAccessToken, error) {
+		atomic.AddInt64(&count, 1)
+		close(started)
+		<-release
+		return &structs.AppAccessToken{AppAccessToken: "app", Expire: time.Now().Unix() + 7200}, nil
+	}
+	ctx := SetIntegrationTokenCacheToCtx(context.Background(), cache)
+
+	results := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			app, err := GetIntegrationAppAccessToken(ctx, "")
+			assert.NoError(t, err)
+			results <- app.AppAccessToken
+		}()
+	}
+	<-started
+
+	// 等待刷新的请求在 ctx 结束时返回，不等待网络请求
+	waitCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := GetIntegrationAppAccessToken(waitCtx, "")
+	assert.Error(t, err)
+
+	// 并发请求共享同一次获取
+	close(release)
+	assert.Equal(t, "app", <-results)
+	assert.Equal(t, "app", <-results)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+}
+
+func TestIntegrationTokenResponse(t *testing.T) {
+	// 接口返回的 expire 为过期时间戳，单位：s
+	data := []byte(`{"expire":1700007200,"tenantAccessToken":"t-xxx","larkAppId":"cli_xxx"}`)
+	token := structs.TenantAccessToken{}
+	assert.NoError(t, utils.JsonUnmarshalBytes(data, &token))
+	assert.Equal(t, "t-xxx", token.TenantAccessToken)
+	assert.Equal(t, int64(1700007200), token.Expire)
+
+	fc := clock.NewFake(time.Unix(1700000000, 0))
+	cache := NewIntegrationTokenCache()
+	cache.SetClock(fc)
+	var count int64
+	cache.fetchTenant = func(ctx context.Context, apiName string) (*structs.TenantAccessToken, error) {
+		atomic.AddInt64(&count, 1)
+		token := &structs.TenantAccessToken{}
+		return token, utils.JsonUnmarshalBytes(data, token)
+	}
+	ctx := SetIntegrationTokenCacheToCtx(context.Background(), cache)
+	for i := 0; i < 3; i++ {
+		_, err := GetIntegrationTenantAccessToken(ctx, "")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+
+	// 剩余有效期不足 10m 时刷新
+	fc.Advance(time.Hour + 51*time.Minute)
+	_, _ = GetIntegrationTenantAccessToken(ctx, "")
+	assert.Equal(t, int64(2), atomic.LoadInt64(&count))
+}
+
+type anonymousCredential struct {
+	tokens []string // 不可比较类型，不能作为 map key
+}
+
+func (c anonymousCredential) GetToken(ctx context.Context) (string, error) {
+	return c.tokens[0], nil
+}
+
+func (c anonymousCredential) InvalidateToken(token string) bool {
+	return false
+}
+
+func TestIntegrationTokenCacheKey(t *testing.T) {
+	fc := clock.NewFake(time.Unix(1700000000, 0))
+	cache := NewIntegrationTokenCache()
+	cache.SetClock(fc)
+	cache.capacity = 3
+	var count int64
+	cache.fetchApp = func(ctx context.Context, apiName string) (*structs.AppAccessToken, error) {
+		n := atomic.AddInt64(&count, 1)
+		return &structs.AppAccessToken{AppAccessToken: apiName + "-" + string(rune('0'+n)), Expire: fc.Now().Unix() + 7200}, nil
+	}
+	ctx := SetIntegrationTokenCacheToCtx(context.Background(), cache)
+
+	// 相同身份的凭证实例共用缓存，不同身份分别缓存
+	ctxA := SetCredentialToCtx(ctx, NewStaticCredential("token-a"))
+	app, _ := GetIntegrationAppAccessToken(ctxA, "lark")
+	assert.Equal(t, "lark-1", app.AppAccessToken)
+	app, _ = GetIntegrationAppAccessToken(SetCredentialToCtx(ctx, NewStaticCredential("token-a")), "lark")
+	assert.Equal(t, "lark-1", app.AppAccessToken)
+	app, _ = GetIntegrationAppAccessToken(SetCredentialToCtx(ctx, NewStaticCredential("token-b")), "lark")
+	assert.Equal(t, "lark-2", app.AppAccessToken)
+	assert.Equal(t, 2, cache.Len())
+
+	// 无法提供身份的凭证（包括不可比较类型）不缓存，也不会 panic
+	ctxAnon := SetCredentialToCtx(ctx, anonymousCredential{tokens: []string{"t"}})
+	assert.NotPanics(t, func() {
+		app, _ = GetIntegrationAppAccessToken(ctxAnon, "lark")
+	})
+	assert.Equal(t, "lark-3", app.AppAccessToken)
+	app, _ = GetIntegrationAppAccessToken(ctxAnon, "lark")
+	assert.Equal(t, "lark-4", app.AppAccessToken)
+	assert.Equal(t, 2, cache.Len())
+
+	// 超出容量时淘汰最久未使用的 key
+	_, _ = GetIntegrationAppAccessToken(ctxA, "lark")
+	_, _ = GetIntegrationAppAccessToken(ctx, "lark")
+	_, _ = GetIntegrationAppAccessToken(ctx, "lark2")
+	assert.Equal(t, 3, cache.Len())
+	app, _ = GetIntegrationAppAccessToken(ctxA, "lark")
+	assert.Equal(t, "lark-1", app.AppAccessToken)
+	app, _ = GetIntegrationAppAccessToken(SetCredentialToCtx(ctx, NewStaticCredential("token-b")), "lark")
+	assert.Equal(t, "lark-7", app.AppAccessToken)
+
+	// 新建 key 时清理已过期的 token
+	fc.Advance(3 * time.Hour)
+	_, _ = GetIntegrationAppAccessToken(ctx, "lark3")
+	assert.Equal(t, 1, cache.Len())
+}
@@ -18,15 +18,12 @@ func AppTokenMiddleware(ctx context.Context, req *http.Request) (err error) {
 		return nil
 	}
 
-	credential := getCredentialFromCtx(ctx)
-	if credential == nil {
-		credential, err = getFaaSCredential()
-		if err != nil {
-			return err
-		}
+	credential, err := resolveCredential(ctx)
+	if err != nil {
+		return err
 	}
 
-	token, err := credential.getToken(ctx)
+	token, err := credential.GetToken(ctx)
 	if err != nil {
 		return err
 	}
@@ -4,24 +4,40 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 
+	"github.com/byted-apaas/server-common-go/constants"
 	"github.com/byted-apaas/server-common-go/utils"
 )
 
 // PressureConfig 反压中心配置，由CloudFunction下发
 type PressureConfig struct {
-	MaxSleeptime   int64 `yaml:"MaxSleeptime" json:"MaxSleeptime"`     // 最大sleeptime，单位：ms，-1表示不限制，0转换默认值
-	UpdateInterval int64 `yaml:"UpdateInterval" json:"UpdateInterval"` // 定时器更新周期，单位：ms，需要 > 0
-	MaxKeyCapacity int   `yaml:"MaxKeyCapacity" json:"MaxKeyCapacity"` // 最大key容量，需要匹配反压中心http接口批量最大容量，超过会对key进行淘汰，需要 > 0
-	EvictThreshold int64 `yaml:"EvictThreshold" json:"EvictThreshold"` // 淘汰阈值，当某个key未请求超过该阈值，则触发淘汰，单位：ms，需要 > 0
+	MaxSleeptime   int64   `yaml:"MaxSleeptime" json:"MaxSleeptime"`     // 最大sleeptime，单位：ms，-1表示不限制，0转换默认值
+	UpdateInterval int64   `yaml:"UpdateInterval" json:"UpdateInterval"` // 定时器更新周期，单位：ms，需要 > 0
+	MaxKeyCapacity int     `yaml:"MaxKeyCapacity" json:"MaxKeyCapacity"` // 最大key容量，需要匹配反压中心http接口批量最大容量，超过会对key进行淘汰，需要 > 0
+	EvictThreshold int64   `yaml:"EvictThreshold" json:"EvictThreshold"` // 淘汰阈值，当某个key未请求超过该阈值，则触发淘汰，单位：ms，需要 > 0
+	PushEnabled    bool    `yaml:"PushEnabled" json:"PushEnabled"`       // 是否开启响应头推送压力信号，开启后首次使用key时异步拉取，不阻塞请求，定时拉取作为兜底
+	JitterRatio    float64 `yaml:"JitterRatio" json:"JitterRatio"`       // 降速抖动比例，实际sleep时长在 [sleeptime*(1-JitterRatio), sleeptime] 间随机，0转换默认值，< 0 表示不抖动
+	FailFast       bool    `yaml:"FailFast" json:"FailFast"`             // sleep时长超过ctx剩余时间时，不再sleep，直接返回降速错误
+
+	SmoothingFactor    float64 `yaml:"SmoothingFactor" json:"SmoothingFactor"`       // EWMA 平滑系数，取值 (0, 1]，越小越平滑，0转换默认值，默认不平滑
+	MaxStepPerInterval int64   `yaml:"MaxStepPerInterval" json:"MaxStepPerInterval"` // 每次拉取sleeptime的最大变化量，单位：ms，<= 0 表示不限制
+	StaleThreshold     int64   `yaml:"StaleThreshold" json:"StaleThreshold"`         // 拉取失败时保留上次成功结果的最长时间，单位：ms，0转换默认值，< 0 表示失败后立即清零
+
+	SnapshotLogInterval int64 `yaml:"SnapshotLogInterval" json:"SnapshotLogInterval"` // 状态快照日志输出周期，单位：ms，0转换默认值，< 0 表示不输出
 }
 
 const (
-	DefaultPressureMaxSleeptime   int64 = 1000          // 1s
-	DefaultPressureUpdateInterval int64 = 5000          // 5s
-	DefaultPressureMaxKeyCapacity int   = 1000          // 默认1000个
-	DefaultPressureEvictThreshold int64 = 1 * 60 * 1000 // 1min
+	DefaultPressureMaxSleeptime   int64   = 1000          // 1s
+	DefaultPressureUpdateInterval int64   = 5000          // 5s
+	DefaultPressureMaxKeyCapacity int     = 1000          // 默认1000个
+	DefaultPressureEvictThreshold int64   = 1 * 60 * 1000 // 1min
+	DefaultPressureJitterRatio    float64 = 0.1           // 10%
+	DefaultPressureSmoothing      float64 = 1             // 不平滑
+	DefaultPressureStaleThreshold int64   = 30 * 1000     // 30s
+
+	DefaultPressureSnapshotLogInterval int64 = 1 * 60 * 1000 // 1min
 )
 
 var (
@@ -30,6 +46,10 @@ var (
 		UpdateInterval: DefaultPressureUpdateInterval,
 		MaxKeyCapacity: DefaultPressureMaxKeyCapacity,
 		EvictThreshold: DefaultPressureEvictThreshold,
+		JitterRatio:    DefaultPressureJitterRatio,
+
+		SmoothingFactor: DefaultPressureSmoothing,
+		StaleThreshold:  DefaultPressureStaleThreshold,
 	}
 )
 
@@ -41,7 +61,7 @@ func NewPressureConfigByJsonStr(data string) *PressureConfig {
 
 	var conf PressureConfig
 	if err := json.Unmarshal([]byte(data), &conf); err != nil {
-		fmt.Println("NewPressureConfigByJsonStr json unmarshal error : ", err.Error())
+		logPressureEvent(context.Background(), utils.LogLevelWarn, &pressureEvent{Event: pressureEventConfigError, Error: err.Error()})
 		conf = defaultPressureConfig
 	}
 
@@ -57,6 +77,17 @@ func NewPressureConfigByJsonStr(data string) *PressureConfig {
 	if conf.EvictThreshold <= 0 {
 		conf.EvictThreshold = DefaultPressureEvictThreshold
 	}
+	if conf.JitterRatio == 0 { // jitter_ratio < 0 表示不抖动，最大为 1
+		conf.JitterRatio = DefaultPressureJitterRatio
+	} else if conf.JitterRatio > 1 {
+		conf.JitterRatio = 1
+	}
+	if conf.SmoothingFactor <= 0 || conf.SmoothingFactor > 1 {
+		conf.SmoothingFactor = DefaultPressureSmoothing
+	}
+	if conf.StaleThreshold == 0 { // stale_threshold < 0 表示失败后立即清零
+		conf.StaleThreshold = DefaultPressureStaleThreshold
+	}
 	return &conf
 }
 
@@ -72,14 +103,28 @@ func InitPressureDecelerator(ctx context.Context) {
 		//client := &MockPressureHttpClient{}
 		client := &PressureHttpClient{}
 		pressureDecelerator = NewPressureDecelerator(ctx, config, client)
-		go func() {
-			pressureDecelerator.RunUpdateTask() // 启动刷新任务
-		}()
+		pressureDecelerator.Start(context.Background()) // 启动刷新任务，生命周期与进程一致，不随请求 ctx 结束
 	})
 	UpdatePressureConfig(config)
 	UpdatePressureContext(ctx)
 }
 
+// UpdatePressureSignals 更新响应头推送的压力信号，pressureDecelerator 未初始化时忽略
+func UpdatePressureSignals(signals map[string]int32) {
+	if pressureDecelerator == nil {
+		return
+	}
+	pressureDecelerator.UpdateSleeptimes(signals)
+}
+
+// GetPressureSnapshot 获取全局降速器的状态快照，pressureDecelerator 未初始化时返回 nil
+func GetPressureSnapshot() *PressureSnapshot {
+	if pressureDecelerator == nil {
+		return nil
+	}
+	return pressureDecelerator.Snapshot()
+}
+
 func UpdatePressureConfig(config *PressureConfig) {
 	pressureDecelerator.setConfig(config)
 }
@@ -87,3 +132,66 @@ func UpdatePressureConfig(config *PressureConfig) {
 func UpdatePressureContext(ctx context.Context) {
 	pressureDecelerator.setContext(ctx)
 }
+
+const (
+	pressureEventConfigError      = "config_error"
+	pressureEventPollError        = "poll_error"
+	pressureEventSignalParseError = "signal_parse_error"
+	pressureEventEvict            = "evict"
+	pressureEventSnapshot         = "snapshot"
+)
+
+// pressureLogMaxKeys 单条降速器日志最多记录的 key 数量，超出部分只记录总数
+const pressureLogMaxKeys = 20
+
+// pressureEvent 降速器结构化日志内容
+type pressureEvent struct {
+	Event     string            `json:"event"`
+	Keys      []string          `json:"keys,omitempty"`
+	TotalKeys int               `json:"total_keys,omitempty"` // Keys 或 Snapshot.Keys 被截断时记录 key 总数
+	Error     string            `json:"error,omitempty"`
+	Snapshot  *PressureSnapshot `json:"snapshot,omitempty"`
+}
+
+// logPressureEvent 以 FormatLog 格式输出降速器日志，进入统一的日志采集链路
+func logPressureEvent(ctx context.Context, level int, event *pressureEvent) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	msg := marshalPressureEvent(event)
+	fmt.Println(utils.NewFormatLog(ctx, level, constants.PressureLogType, string(msg)).String())
+}
+
+// marshalPressureEvent 序列化日志内容，限制 key 数量使结果不超过 LogLengthLimit，避免被截断为非法 JSON。
+// 快照只保留 sleeptime 最大的 key
+func marshalPressureEvent(event *pressureEvent) []byte {
+	e := *event
+	keys := event.Keys
+	var states []PressureKeyState
+	var snapshot PressureSnapshot
+	if event.Snapshot != nil {
+		snapshot = *event.Snapshot
+		states = append(states, snapshot.Keys...)
+		sort.SliceStable(states, func(i, j int) bool {
+			return states[i].Sleeptime > states[j].Sleeptime
+		})
+		e.Snapshot = &snapshot
+	}
+
+	for n := pressureLogMaxKeys; ; n /= 2 {
+		e.Keys, e.TotalKeys = keys, 0
+		if len(keys) > n {
+			e.Keys, e.TotalKeys = keys[:n], len(keys)
+		}
+		if e.Snapshot != nil {
+			snapshot.Keys = states
+			if len(states) > n {
+				snapshot.Keys, e.TotalKeys = states[:n], len(states)
+			}
+		}
+		msg, _ := json.Marshal(&e)
+		if len(msg) <= utils.LogLengthLimit || n == 0 {
+			return msg
+		}
+	}
+}
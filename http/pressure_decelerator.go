@@ -2,33 +2,108 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
 )
 
 // 反压中心 - 降速
 
+// ErrDecelerated sleep时长超过ctx剩余时间，快速失败
+var ErrDecelerated = errors.New("request decelerated by pressure center")
+
+// DecelerateError 反压降速错误，Err 为 ErrDecelerated 或 ctx.Err()
+type DecelerateError struct {
+	Key       string
+	SleepTime time.Duration // 计划sleep时长
+	Slept     time.Duration // 实际sleep时长
+	Err       error
+}
+
+func (e *DecelerateError) Error() string {
+	return fmt.Sprintf("pressure decelerate interrupted, key: %s, sleeptime: %v, slept: %v, err: %v", e.Key, e.SleepTime, e.Slept, e.Err)
+}
+
+func (e *DecelerateError) Unwrap() error {
+	return e.Err
+}
+
 // PressureDecelerator 反压中心降速缓存结构体
 type PressureDecelerator struct {
 	config atomic.Value // *PressureConfig
 	ctx    atomic.Value // context.Context
 	client IPressureHttpClient
+	clock  clock.Clock
 
 	cache sync.Map // map[string]*PressureDeceleratorItem
 	size  int64    // current cache size
 
-	ticker   *time.Ticker
-	updating int32 // ticker update task mutex
+	lifecycle sync.Mutex         // Start/Stop mutex
+	loopCtx   context.Context    // update task ctx, cancelled by Stop
+	cancel    context.CancelFunc // update task cancel func, nil means stopped
+	done      chan struct{}      // closed when update loop exits
+	resetCh   chan time.Duration // update interval change notification
+	wg        *sync.WaitGroup    // update loop and update task goroutines of current run
+	updating  int32              // ticker update task mutex
+
+	evictions       int64        // total evicted key count
+	polls           int64        // total poll count
+	pollErrors      int64        // total poll error count
+	lastPollTime    int64        // last poll time, unit: ms
+	lastPollLatency int64        // last poll latency, unit: ms
+	lastPollError   atomic.Value // string
+	lastSnapshotLog int64        // last snapshot log time, unit: ms
 }
 
 type PressureDeceleratorItem struct {
 	first       sync.Once // first time load
+	pushed      int32     // 已有推送值，首次使用时无需拉取
 	key         string
-	sleeptime   int32 // unit: ms
+	sleeptime   int32 // smoothed sleeptime, unit: ms
 	lastReqTime int64 // last request time, unit: ms
+
+	lock           sync.Mutex // state update mutex, sleeptime is still read atomically
+	rawSleeptime   int32      // last sleeptime returned by pressure center, unit: ms
+	lastUpdateTime int64      // last successful update time, unit: ms
+	errorCount     int64      // consecutive update error count
+	lastError      string     // last update error
+
+	totalSleep int64 // cumulative sleep applied, unit: ms
+	sleepCount int64 // decelerated request count
+}
+
+// PressureKeyState 单个key的降速状态
+type PressureKeyState struct {
+	Key            string `json:"key"`
+	RawSleeptime   int32  `json:"raw_sleeptime"`    // 反压中心返回的sleep时长，单位：ms
+	Sleeptime      int32  `json:"sleeptime"`        // 平滑后实际使用的sleep时长，单位：ms
+	LastReqTime    int64  `json:"last_req_time"`    // 最近一次请求时间，单位：ms
+	LastUpdateTime int64  `json:"last_update_time"` // 最近一次成功更新时间，单位：ms
+	ErrorCount     int64  `json:"error_count"`      // 连续更新失败次数
+	LastError      string `json:"last_error,omitempty"`
+	TotalSleep     int64  `json:"total_sleep"` // 累计实际降速时长，单位：ms
+	SleepCount     int64  `json:"sleep_count"` // 累计降速请求数
+}
+
+// PressureSnapshot 降速器状态快照
+type PressureSnapshot struct {
+	Time            int64              `json:"time"`              // 快照时间，单位：ms
+	TrackedKeys     int64              `json:"tracked_keys"`      // 当前缓存的key数量
+	Evictions       int64              `json:"evictions"`         // 累计淘汰key数量
+	Polls           int64              `json:"polls"`             // 累计拉取次数
+	PollErrors      int64              `json:"poll_errors"`       // 累计拉取失败次数
+	LastPollTime    int64              `json:"last_poll_time"`    // 最近一次拉取时间，单位：ms
+	LastPollLatency int64              `json:"last_poll_latency"` // 最近一次拉取耗时，单位：ms
+	LastPollError   string             `json:"last_poll_error,omitempty"`
+	Keys            []PressureKeyState `json:"keys"`
 }
 
 func NewPressureDecelerator(ctx context.Context, config *PressureConfig, client IPressureHttpClient) *PressureDecelerator {
@@ -37,19 +112,26 @@ func NewPressureDecelerator(ctx context.Context, config *PressureConfig, client
 	}
 
 	pd := &PressureDecelerator{
-		client: client,
-		size:   0,
+		client:  client,
+		clock:   clock.Default(),
+		size:    0,
+		resetCh: make(chan time.Duration, 1),
 	}
 	pd.setConfig(config)
 	pd.setContext(ctx)
-	//if pd.config.UpdateInterval <= 0 {
-	//	panic("pressure decelerator config update interval must be a positive number")
-	//}
-	pd.ticker = time.NewTicker(time.Duration(config.UpdateInterval) * time.Millisecond)
 
 	return pd
 }
 
+// SetClock 替换时钟，需要在 Start 之前调用，用于测试
+func (pd *PressureDecelerator) SetClock(c clock.Clock) {
+	pd.clock = clock.OrDefault(c)
+}
+
+func (pd *PressureDecelerator) nowMs() int64 {
+	return pd.clock.Now().UnixNano() / 1e6
+}
+
 func (pd *PressureDecelerator) getContext() context.Context {
 	return pd.ctx.Load().(context.Context)
 }
@@ -58,12 +140,52 @@ func (pd *PressureDecelerator) setContext(ctx context.Context) {
 	pd.ctx.Store(ctx)
 }
 
+// pollContext 拉取使用的 ctx：取值来自 pd.ctx，取消与超时跟随刷新任务的 ctx，Stop 时中断进行中的拉取
+type pollContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c pollContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+func (pd *PressureDecelerator) pollContext(loopCtx context.Context) context.Context {
+	return pollContext{Context: loopCtx, values: pd.getContext()}
+}
+
 func (pd *PressureDecelerator) getConfig() *PressureConfig {
 	return pd.config.Load().(*PressureConfig) // pd.config need to be not nil
 }
 
 func (pd *PressureDecelerator) setConfig(config *PressureConfig) {
+	old, _ := pd.config.Load().(*PressureConfig)
 	pd.config.Store(config)
+	if old != nil && old.UpdateInterval != config.UpdateInterval {
+		pd.resetInterval(updateIntervalOf(config))
+	}
+}
+
+// resetInterval 通知刷新任务重置定时器周期，只保留最新的周期
+func (pd *PressureDecelerator) resetInterval(interval time.Duration) {
+	for {
+		select {
+		case pd.resetCh <- interval:
+			return
+		default:
+			select {
+			case <-pd.resetCh:
+			default:
+			}
+		}
+	}
+}
+
+func updateIntervalOf(config *PressureConfig) time.Duration {
+	if config.UpdateInterval <= 0 {
+		return time.Duration(DefaultPressureUpdateInterval) * time.Millisecond
+	}
+	return time.Duration(config.UpdateInterval) * time.Millisecond
 }
 
 func (pd *PressureDecelerator) GetSleeptime(key string) int32 {
@@ -72,109 +194,405 @@ func (pd *PressureDecelerator) GetSleeptime(key string) int32 {
 		return 0
 	}
 
+	item := pd.loadOrStoreItem(key)
+	item.first.Do(func() {
+		if atomic.LoadInt32(&item.pushed) == 1 { // 已有推送值
+			return
+		}
+		if pd.getConfig().PushEnabled && pd.goUpdateOne(item) { // 开启推送后，首次拉取不阻塞请求
+			return
+		}
+		pd.updateOne(pd.getContext(), item)
+	})
+	atomic.StoreInt64(&item.lastReqTime, pd.nowMs())
+
+	return atomic.LoadInt32(&item.sleeptime)
+}
+
+// goUpdateOne 刷新任务运行中时异步拉取，并纳入 Stop 的等待范围，未运行时返回 false
+func (pd *PressureDecelerator) goUpdateOne(item *PressureDeceleratorItem) bool {
+	pd.lifecycle.Lock()
+	defer pd.lifecycle.Unlock()
+
+	if pd.cancel == nil {
+		return false
+	}
+	ctx, wg := pd.pollContext(pd.loopCtx), pd.wg
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pd.updateOne(ctx, item)
+	}()
+	return true
+}
+
+func (pd *PressureDecelerator) loadOrStoreItem(key string) *PressureDeceleratorItem {
 	value, ok := pd.cache.Load(key)
 	if !ok {
 		var loaded bool
 		if value, loaded = pd.cache.LoadOrStore(key, &PressureDeceleratorItem{
 			key:         key,
-			lastReqTime: getCurrentTimestampMs(),
+			lastReqTime: pd.nowMs(),
 		}); !loaded {
 			atomic.AddInt64(&pd.size, 1)
 		}
 	}
+	return value.(*PressureDeceleratorItem)
+}
+
+func (pd *PressureDecelerator) updateOne(ctx context.Context, item *PressureDeceleratorItem) {
+
+	start := pd.clock.Now()
+	st, err := pd.client.GetSleeptime(ctx, item.key)
+	pd.recordPoll(start, err)
+	if err != nil {
+		logPressureEvent(ctx, utils.LogLevelWarn, &pressureEvent{Event: pressureEventPollError, Keys: []string{item.key}, Error: err.Error()})
+		pd.applyError(item, err, pd.nowMs())
+		return
+	}
+	pd.applySleeptime(item, st, pd.nowMs())
+}
+
+// recordPoll 记录拉取次数、耗时与错误
+func (pd *PressureDecelerator) recordPoll(start time.Time, err error) {
+	atomic.AddInt64(&pd.polls, 1)
+	atomic.StoreInt64(&pd.lastPollTime, utils.TimeMils(start))
+	atomic.StoreInt64(&pd.lastPollLatency, pd.clock.Since(start).Milliseconds())
+	if err != nil {
+		atomic.AddInt64(&pd.pollErrors, 1)
+		pd.lastPollError.Store(err.Error())
+	} else {
+		pd.lastPollError.Store("")
+	}
+}
 
+// recordSleep 记录key的累计降速时长
+func (pd *PressureDecelerator) recordSleep(key string, slept time.Duration) {
+	value, ok := pd.cache.Load(key)
+	if !ok {
+		return
+	}
 	item := value.(*PressureDeceleratorItem)
-	item.first.Do(func() {
-		pd.updateOne(item)
+	atomic.AddInt64(&item.totalSleep, slept.Milliseconds())
+	atomic.AddInt64(&item.sleepCount, 1)
+}
+
+// applySleeptime 应用拉取到的sleep时长，按配置进行平滑与步长限制
+func (pd *PressureDecelerator) applySleeptime(item *PressureDeceleratorItem, raw int32, now int64) {
+	config := pd.getConfig()
+	if maxSleeptime := config.MaxSleeptime; maxSleeptime > 0 { // max_sleeptime < 0 表示不限制，max_sleeptime > 0，最大为 max_sleeptime
+		raw = minInt32(raw, int32(maxSleeptime))
+	}
+
+	item.lock.Lock()
+	defer item.lock.Unlock()
+
+	item.rawSleeptime = raw
+	item.lastUpdateTime = now
+	item.errorCount = 0
+	item.lastError = ""
+	atomic.StoreInt32(&item.sleeptime, smoothSleeptime(atomic.LoadInt32(&item.sleeptime), raw, config))
+}
+
+// applyError 拉取失败时保留上次成功的sleep时长，超过 StaleThreshold 后清零
+func (pd *PressureDecelerator) applyError(item *PressureDeceleratorItem, err error, now int64) {
+	staleThreshold := pd.getConfig().StaleThreshold
+
+	item.lock.Lock()
+	defer item.lock.Unlock()
+
+	item.errorCount++
+	item.lastError = err.Error()
+	if staleThreshold < 0 || now-item.lastUpdateTime > staleThreshold {
+		item.rawSleeptime = 0
+		atomic.StoreInt32(&item.sleeptime, 0)
+	}
+}
+
+// smoothSleeptime 按 SmoothingFactor 进行 EWMA 平滑，并按 MaxStepPerInterval 限制单次变化量
+func smoothSleeptime(prev, raw int32, config *PressureConfig) int32 {
+	next := float64(raw)
+	if alpha := config.SmoothingFactor; alpha > 0 && alpha < 1 {
+		next = float64(prev) + alpha*(float64(raw)-float64(prev))
+	}
+	if step := float64(config.MaxStepPerInterval); step > 0 {
+		if next > float64(prev)+step {
+			next = float64(prev) + step
+		} else if next < float64(prev)-step {
+			next = float64(prev) - step
+		}
+	}
+	if next < 0 {
+		return 0
+	}
+	return int32(math.Round(next))
+}
+
+// Snapshot 获取所有key的降速状态快照，按key排序
+func (pd *PressureDecelerator) Snapshot() *PressureSnapshot {
+	lastPollError, _ := pd.lastPollError.Load().(string)
+	snapshot := &PressureSnapshot{
+		Time:            pd.nowMs(),
+		TrackedKeys:     atomic.LoadInt64(&pd.size),
+		Evictions:       atomic.LoadInt64(&pd.evictions),
+		Polls:           atomic.LoadInt64(&pd.polls),
+		PollErrors:      atomic.LoadInt64(&pd.pollErrors),
+		LastPollTime:    atomic.LoadInt64(&pd.lastPollTime),
+		LastPollLatency: atomic.LoadInt64(&pd.lastPollLatency),
+		LastPollError:   lastPollError,
+		Keys:            make([]PressureKeyState, 0, atomic.LoadInt64(&pd.size)),
+	}
+	pd.cache.Range(func(key, value interface{}) bool {
+		item := value.(*PressureDeceleratorItem)
+		item.lock.Lock()
+		snapshot.Keys = append(snapshot.Keys, PressureKeyState{
+			Key:            item.key,
+			RawSleeptime:   item.rawSleeptime,
+			Sleeptime:      atomic.LoadInt32(&item.sleeptime),
+			LastReqTime:    atomic.LoadInt64(&item.lastReqTime),
+			LastUpdateTime: item.lastUpdateTime,
+			ErrorCount:     item.errorCount,
+			LastError:      item.lastError,
+			TotalSleep:     atomic.LoadInt64(&item.totalSleep),
+			SleepCount:     atomic.LoadInt64(&item.sleepCount),
+		})
+		item.lock.Unlock()
+		return true
 	})
-	atomic.StoreInt64(&item.lastReqTime, getCurrentTimestampMs())
+	sort.Slice(snapshot.Keys, func(i, j int) bool {
+		return snapshot.Keys[i].Key < snapshot.Keys[j].Key
+	})
+	return snapshot
+}
 
-	return atomic.LoadInt32(&item.sleeptime)
+// Decelerate 按sleep时长执行降速，返回实际sleep时长
+// - sleep时长按 JitterRatio 随机抖动，避免所有请求同时唤醒
+// - ctx 结束时提前返回 DecelerateError
+// - 开启 FailFast 且sleep时长超过ctx剩余时间时，不sleep直接返回 DecelerateError
+func (pd *PressureDecelerator) Decelerate(ctx context.Context, key string, sleeptime int32) (time.Duration, error) {
+	if sleeptime <= 0 {
+		return 0, nil
+	}
+
+	sleepDuration := jitterDuration(time.Duration(sleeptime)*time.Millisecond, pd.getConfig().JitterRatio)
+	if deadline, ok := ctx.Deadline(); ok && pd.getConfig().FailFast && deadline.Sub(pd.clock.Now()) < sleepDuration {
+		return 0, &DecelerateError{Key: key, SleepTime: sleepDuration, Err: ErrDecelerated}
+	}
+
+	start := pd.clock.Now()
+	timer := pd.clock.NewTimer(sleepDuration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		slept := pd.clock.Since(start)
+		pd.recordSleep(key, slept)
+		return slept, &DecelerateError{Key: key, SleepTime: sleepDuration, Slept: slept, Err: ctx.Err()}
+	case <-timer.C():
+		slept := pd.clock.Since(start)
+		pd.recordSleep(key, slept)
+		return slept, nil
+	}
 }
 
-func (pd *PressureDecelerator) updateOne(item *PressureDeceleratorItem) {
+// jitterDuration 在 [d*(1-ratio), d] 间随机，ratio <= 0 时不抖动
+func jitterDuration(d time.Duration, ratio float64) time.Duration {
+	if ratio <= 0 || d <= 0 {
+		return d
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+	return d - time.Duration(rand.Float64()*ratio*float64(d))
+}
 
-	st, err := pd.client.GetSleeptime(pd.getContext(), item.key)
-	if err != nil {
-		fmt.Println("PressureDecelerator http client GetSleeptime error : ", err.Error())
+// UpdateSleeptimes 使用服务端推送的压力信号立即更新sleep时长，定时拉取仍然作为兜底。
+// 在响应处理路径中调用，不阻塞；缓存已达 MaxKeyCapacity 时只更新已缓存的key
+func (pd *PressureDecelerator) UpdateSleeptimes(signals map[string]int32) {
+	for key, st := range signals {
+		if key == "" {
+			continue
+		}
+
+		var item *PressureDeceleratorItem
+		if value, ok := pd.cache.Load(key); ok {
+			item = value.(*PressureDeceleratorItem)
+		} else if atomic.LoadInt64(&pd.size) < int64(pd.getConfig().MaxKeyCapacity) {
+			item = pd.loadOrStoreItem(key)
+		} else {
+			continue
+		}
+		atomic.StoreInt32(&item.pushed, 1) // 不调用 first.Do，避免等待进行中的首次拉取
+		if maxSleeptime := pd.getConfig().MaxSleeptime; maxSleeptime > 0 {
+			st = minInt32(st, int32(maxSleeptime))
+		}
+
+		// 推送值是服务端的最新结论，不做平滑
+		item.lock.Lock()
+		item.rawSleeptime = st
+		item.lastUpdateTime = pd.nowMs()
+		item.errorCount = 0
+		item.lastError = ""
+		atomic.StoreInt32(&item.sleeptime, st)
+		item.lock.Unlock()
+	}
+}
+
+// Start 启动定时刷新任务，重复调用无副作用，Stop 后可重新启动
+func (pd *PressureDecelerator) Start(ctx context.Context) {
+	pd.lifecycle.Lock()
+	defer pd.lifecycle.Unlock()
+
+	if pd.cancel != nil {
+		select {
+		case <-pd.done: // 刷新任务已随 ctx 结束退出，允许重新启动，进行中的拉取已随 ctx 取消
+			pd.cancel()
+		default:
+			return
+		}
 	}
-	if maxSleeptime := pd.getConfig().MaxSleeptime; maxSleeptime > 0 { // max_sleeptime < 0 表示不限制，max_sleeptime > 0，最大为 max_sleeptime
-		st = minInt32(st, int32(maxSleeptime))
+
+	// 丢弃启动前的周期变更通知，启动时直接使用最新配置
+	select {
+	case <-pd.resetCh:
+	default:
 	}
 
-	atomic.StoreInt32(&item.sleeptime, st)
+	pd.loopCtx, pd.cancel = context.WithCancel(ctx)
+	pd.done = make(chan struct{})
+	pd.wg = &sync.WaitGroup{}
+	pd.wg.Add(1)
+	go pd.runUpdateLoop(pd.loopCtx, pd.wg, pd.done, updateIntervalOf(pd.getConfig()))
 }
 
-func (pd *PressureDecelerator) RunUpdateTask() {
-	// pd.config.UpdateInterval > 0 && pd.ticker != nil
-	for range pd.ticker.C {
-		go func() {
-			if atomic.CompareAndSwapInt32(&pd.updating, 0, 1) { // 定时器更新任务间互斥
-				defer atomic.StoreInt32(&pd.updating, 0) // 解锁
-				now := getCurrentTimestampMs()
-				updateKeys := make([]string, 0, atomic.LoadInt64(&pd.size)+20) // 多设置20个预留，可能中途有新增的
-				sortKeys := make(map[string]int64, atomic.LoadInt64(&pd.size))
-				evictKeys := make([]string, 0, atomic.LoadInt64(&pd.size)) // 还是记录淘汰key列表，使用updateKeys取反会把中途新增的新key也淘汰掉
-				pd.cache.Range(func(key, value interface{}) bool {
-					item := value.(*PressureDeceleratorItem)
-					if lastReqTime := atomic.LoadInt64(&item.lastReqTime); now-lastReqTime <= pd.getConfig().EvictThreshold {
-						updateKeys = append(updateKeys, item.key)
-						sortKeys[item.key] = lastReqTime
-					} else {
-						evictKeys = append(evictKeys, item.key)
-					}
-					return true
-				})
-				pd.evictKeys(evictKeys)
-				if len(updateKeys) == 0 { // 更新key列表为空，则直接返回
-					return
-				}
-				sort.Slice(updateKeys, func(i, j int) bool { // 按last_req_time降序排序
-					return sortKeys[updateKeys[i]] > sortKeys[updateKeys[j]]
-				})
-				if maxKeyCap := pd.getConfig().MaxKeyCapacity; len(updateKeys) > maxKeyCap { // 仍超过最大容量，淘汰最早的
-					pd.evictKeys(updateKeys[maxKeyCap:])
-					updateKeys = updateKeys[:maxKeyCap]
-				}
-				res, err := pd.client.BatchGetSleeptime(pd.getContext(), updateKeys)
-				if err != nil {
-					fmt.Printf("PressureDecelerator update ticker [%d] error : %+v\n", now, err)
-					//res = make(map[string]int64) // nil map 不可写，但可读，因此不做处理
-				}
-				for _, key := range updateKeys {
-					value, ok := pd.cache.Load(key)
-					if !ok || value == nil {
-						continue
-					}
-					item := value.(*PressureDeceleratorItem)
-					if maxSleeptime := pd.getConfig().MaxSleeptime; maxSleeptime > 0 {
-						atomic.StoreInt32(&item.sleeptime, minInt32(res[key], int32(maxSleeptime)))
-					} else {
-						atomic.StoreInt32(&item.sleeptime, res[key])
-					}
-				}
+// Stop 停止定时刷新任务，取消进行中的拉取并等待其结束；等待时不持有锁，不阻塞请求路径
+func (pd *PressureDecelerator) Stop() {
+	pd.lifecycle.Lock()
+	if pd.cancel == nil {
+		pd.lifecycle.Unlock()
+		return
+	}
+	pd.cancel()
+	wg := pd.wg
+	pd.loopCtx, pd.cancel, pd.done, pd.wg = nil, nil, nil, nil
+	pd.lifecycle.Unlock()
+
+	wg.Wait()
+}
+
+func (pd *PressureDecelerator) runUpdateLoop(ctx context.Context, wg *sync.WaitGroup, done chan struct{}, interval time.Duration) {
+	defer wg.Done()
+	defer close(done)
+
+	ticker := pd.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case interval = <-pd.resetCh:
+			ticker.Reset(interval)
+		case <-ticker.C():
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pd.update(ctx)
+			}()
+		}
+	}
+}
+
+// update 定时器刷新任务，淘汰过期key并批量拉取sleep时长，ctx 取消时中断拉取
+func (pd *PressureDecelerator) update(ctx context.Context) {
+	if atomic.CompareAndSwapInt32(&pd.updating, 0, 1) { // 定时器更新任务间互斥
+		defer atomic.StoreInt32(&pd.updating, 0) // 解锁
+		now := pd.nowMs()
+		defer pd.logSnapshotIfNeeded(now)
+		updateKeys := make([]string, 0, atomic.LoadInt64(&pd.size)+20) // 多设置20个预留，可能中途有新增的
+		sortKeys := make(map[string]int64, atomic.LoadInt64(&pd.size))
+		evictKeys := make([]string, 0, atomic.LoadInt64(&pd.size)) // 还是记录淘汰key列表，使用updateKeys取反会把中途新增的新key也淘汰掉
+		pd.cache.Range(func(key, value interface{}) bool {
+			item := value.(*PressureDeceleratorItem)
+			if lastReqTime := atomic.LoadInt64(&item.lastReqTime); now-lastReqTime <= pd.getConfig().EvictThreshold {
+				updateKeys = append(updateKeys, item.key)
+				sortKeys[item.key] = lastReqTime
+			} else {
+				evictKeys = append(evictKeys, item.key)
+			}
+			return true
+		})
+		pd.evictKeys(evictKeys)
+		if len(updateKeys) == 0 { // 更新key列表为空，则直接返回
+			return
+		}
+		sort.Slice(updateKeys, func(i, j int) bool { // 按last_req_time降序排序
+			return sortKeys[updateKeys[i]] > sortKeys[updateKeys[j]]
+		})
+		if maxKeyCap := pd.getConfig().MaxKeyCapacity; len(updateKeys) > maxKeyCap { // 仍超过最大容量，淘汰最早的
+			pd.evictKeys(updateKeys[maxKeyCap:])
+			updateKeys = updateKeys[:maxKeyCap]
+		}
+		start := pd.clock.Now()
+		res, err := pd.client.BatchGetSleeptime(pd.pollContext(ctx), updateKeys)
+		pd.recordPoll(start, err)
+		if err != nil {
+			logPressureEvent(pd.getContext(), utils.LogLevelWarn, &pressureEvent{Event: pressureEventPollError, Keys: updateKeys, Error: err.Error()})
+		}
+		for _, key := range updateKeys {
+			value, ok := pd.cache.Load(key)
+			if !ok || value == nil {
+				continue
+			}
+			item := value.(*PressureDeceleratorItem)
+			if err != nil { // 拉取失败，保留上次成功的结果
+				pd.applyError(item, err, now)
+				continue
 			}
-		}()
+			pd.applySleeptime(item, res[key], now)
+		}
 	}
 }
 
+// RunUpdateTask 启动定时刷新任务
+// Deprecated: use Start instead
+func (pd *PressureDecelerator) RunUpdateTask() {
+	pd.Start(context.Background())
+}
+
 // evictKeys 淘汰keys
 // 当前淘汰策略：1.last_req_time超出阈值的key；2.当前缓存超过最大容量，淘汰last_req_time最小的key
 func (pd *PressureDecelerator) evictKeys(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
 	//atomic.AddInt64(&pd.size, -int64(len(keys)))
 	for _, key := range keys {
 		pd.cache.Delete(key)
 		atomic.AddInt64(&pd.size, -1)
 	}
+	atomic.AddInt64(&pd.evictions, int64(len(keys)))
+	logPressureEvent(pd.getContext(), utils.LogLevelInfo, &pressureEvent{Event: pressureEventEvict, Keys: keys})
 }
 
-func (pd *PressureDecelerator) StopUpdateTask() {
-	pd.ticker.Stop()
+// logSnapshotIfNeeded 按 SnapshotLogInterval 周期输出状态快照日志
+func (pd *PressureDecelerator) logSnapshotIfNeeded(now int64) {
+	interval := pd.getConfig().SnapshotLogInterval
+	if interval < 0 {
+		return
+	}
+	if interval == 0 {
+		interval = DefaultPressureSnapshotLogInterval
+	}
+	last := atomic.LoadInt64(&pd.lastSnapshotLog)
+	if now-last < interval || !atomic.CompareAndSwapInt64(&pd.lastSnapshotLog, last, now) {
+		return
+	}
+	logPressureEvent(pd.getContext(), utils.LogLevelInfo, &pressureEvent{Event: pressureEventSnapshot, Snapshot: pd.Snapshot()})
 }
 
-func getCurrentTimestampMs() int64 {
-	// time.Now().UnixNano() / int64(time.Millisecond)
-	return time.Now().UnixNano() / 1e6
+// StopUpdateTask 停止定时刷新任务
+// Deprecated: use Stop instead
+func (pd *PressureDecelerator) StopUpdateTask() {
+	pd.Stop()
 }
 
 func minInt32(a, b int32) int32 {
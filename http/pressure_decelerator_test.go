@@ -2,10 +2,19 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
 )
 
 func TestPressureDecelerator(t *testing.T) {
@@ -17,23 +26,26 @@ func TestPressureDecelerator(t *testing.T) {
 		UpdateInterval: 1000,
 	}
 	cli := &MockPressureHttpClient{}
+	fc := clock.NewFake(time.Unix(0, 0))
 	pd := NewPressureDecelerator(ctx, conf, cli)
-	go func() { pd.RunUpdateTask() }()
-	defer pd.StopUpdateTask()
+	pd.SetClock(fc)
 	assert.Equal(t, int32(500), pd.GetSleeptime("key1"))
-	time.Sleep(200 * time.Millisecond)
+	fc.Advance(200 * time.Millisecond)
+	pd.update(context.Background())
 	_, ok := pd.cache.Load("key1")
 	assert.True(t, ok)
-	time.Sleep(time.Duration(conf.EvictThreshold+conf.UpdateInterval) * time.Millisecond)
+	fc.Advance(time.Duration(conf.EvictThreshold+conf.UpdateInterval) * time.Millisecond)
+	pd.update(context.Background())
 	_, ok = pd.cache.Load("key1")
 	assert.False(t, ok)
 	pd.GetSleeptime("key1")
-	time.Sleep(10 * time.Millisecond)
+	fc.Advance(10 * time.Millisecond)
 	pd.GetSleeptime("key2")
-	time.Sleep(10 * time.Millisecond)
+	fc.Advance(10 * time.Millisecond)
 	pd.GetSleeptime("key3")
 	pd.GetSleeptime("key4")
-	time.Sleep(time.Duration(conf.UpdateInterval) * time.Millisecond)
+	fc.Advance(time.Duration(conf.UpdateInterval) * time.Millisecond)
+	pd.update(context.Background())
 	_, ok = pd.cache.Load("key1")
 	assert.False(t, ok)
 	_, ok = pd.cache.Load("key2")
@@ -60,8 +72,8 @@ func BenchmarkPressureDecelerator(b *testing.B) {
 	}
 	cli := &MockPressureHttpClient{}
 	pd := NewPressureDecelerator(ctx, conf, cli)
-	go func() { pd.RunUpdateTask() }()
-	defer pd.StopUpdateTask()
+	pd.Start(ctx)
+	defer pd.Stop()
 	keys := []string{"key1", "key2", "key3", "key4"}
 	for i := 0; i < b.N; i++ {
 		pd.GetSleeptime(keys[i%4])
@@ -88,10 +100,412 @@ func BenchmarkPressureDeceleratorWithEvictKeys(b *testing.B) {
 	}
 	cli := &MockPressureHttpClient{}
 	pd := NewPressureDecelerator(ctx, conf, cli)
-	go func() { pd.RunUpdateTask() }()
-	defer pd.StopUpdateTask()
+	pd.Start(ctx)
+	defer pd.Stop()
 	keys := []string{"key1", "key2", "key3", "key4", "key5", "key6", "key7", "key8"}
 	for i := 0; i < b.N; i++ {
 		pd.GetSleeptime(keys[i%8])
 	}
 }
+
+func TestPressureDeceleratorPushedSleeptimes(t *testing.T) {
+	ctx := context.Background()
+	conf := &PressureConfig{
+		MaxSleeptime:   500,
+		MaxKeyCapacity: 3,
+		EvictThreshold: 2000,
+		UpdateInterval: 1000,
+		PushEnabled:    true,
+	}
+	pd := NewPressureDecelerator(ctx, conf, &MockPressureHttpClient{})
+	pd.Start(ctx)
+	defer pd.Stop()
+
+	// 推送值直接生效，且超过最大值时截断
+	pd.UpdateSleeptimes(map[string]int32{"key1": 200, "key2": 800})
+	assert.Equal(t, int32(200), pd.GetSleeptime("key1"))
+	assert.Equal(t, int32(500), pd.GetSleeptime("key2"))
+
+	// 开启推送后首次使用不阻塞，异步拉取后生效
+	pd.GetSleeptime("key3")
+	assert.Eventually(t, func() bool { return pd.GetSleeptime("key3") == 500 }, time.Second, 10*time.Millisecond)
+
+	signals, err := parsePressureSignals(`{"key4": 100}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int32{"key4": 100}, signals)
+
+	// 缓存已满时不再新增推送的key，已缓存的key仍然更新
+	pd.UpdateSleeptimes(map[string]int32{"key4": 100, "key1": 300})
+	assert.Equal(t, int64(3), pd.Snapshot().TrackedKeys)
+	assert.Equal(t, int32(300), pd.GetSleeptime("key1"))
+}
+
+type blockingPressureHttpClient struct {
+	MockPressureHttpClient
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingPressureHttpClient) GetSleeptime(ctx context.Context, key string) (int32, error) {
+	close(c.started)
+	<-c.release
+	return 100, nil
+}
+
+func TestPressureDeceleratorPushNotBlocked(t *testing.T) {
+	conf := &PressureConfig{MaxSleeptime: 500, MaxKeyCapacity: 3, EvictThreshold: 2000, UpdateInterval: 1000}
+	cli := &blockingPressureHttpClient{started: make(chan struct{}), release: make(chan struct{})}
+	pd := NewPressureDecelerator(context.Background(), conf, cli)
+
+	// 首次同步拉取进行中时，推送不等待
+	go pd.GetSleeptime("key1")
+	<-cli.started
+	pushed := make(chan struct{})
+	go func() {
+		pd.UpdateSleeptimes(map[string]int32{"key1": 200})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("UpdateSleeptimes blocked by first fetch")
+	}
+	close(cli.release)
+}
+
+func TestPressureDeceleratorStopWaitsAsyncFetch(t *testing.T) {
+	conf := &PressureConfig{MaxSleeptime: 500, MaxKeyCapacity: 3, EvictThreshold: 2000, UpdateInterval: 60 * 1000, PushEnabled: true}
+	cli := &blockingPressureHttpClient{started: make(chan struct{}), release: make(chan struct{})}
+	pd := NewPressureDecelerator(context.Background(), conf, cli)
+	pd.Start(context.Background())
+
+	// 推送模式的首次异步拉取纳入 Stop 的等待范围
+	assert.Equal(t, int32(0), pd.GetSleeptime("key1"))
+	<-cli.started
+	stopped := make(chan struct{})
+	go func() {
+		pd.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned before async fetch finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(cli.release)
+	<-stopped
+	assert.Equal(t, int32(100), pd.GetSleeptime("key1"))
+}
+
+func TestPressureDeceleratorDecelerate(t *testing.T) {
+	conf := &PressureConfig{
+		MaxSleeptime:   500,
+		MaxKeyCapacity: 3,
+		EvictThreshold: 2000,
+		UpdateInterval: 1000,
+		JitterRatio:    0.5,
+	}
+	fc := clock.NewFake(time.Now())
+	pd := NewPressureDecelerator(context.Background(), conf, &MockPressureHttpClient{})
+	pd.SetClock(fc)
+
+	// 抖动后的sleep时长在 [50%, 100%] 之间
+	sleptCh := make(chan time.Duration)
+	go func() {
+		slept, err := pd.Decelerate(context.Background(), "key1", 40)
+		assert.NoError(t, err)
+		sleptCh <- slept
+	}()
+	waitForWaiters(t, fc, 1)
+	fc.Advance(40 * time.Millisecond)
+	slept := <-sleptCh
+	assert.True(t, slept >= 20*time.Millisecond && slept <= 40*time.Millisecond)
+
+	// ctx 结束时提前返回
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slept, err := pd.Decelerate(ctx, "key1", 1000)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, time.Duration(0), slept)
+	assert.Equal(t, 0, fc.Waiters())
+
+	// 快速失败
+	pd.setConfig(&PressureConfig{MaxSleeptime: 500, MaxKeyCapacity: 3, EvictThreshold: 2000, UpdateInterval: 1000, FailFast: true})
+	ctx, cancel = context.WithDeadline(context.Background(), fc.Now().Add(10*time.Millisecond))
+	defer cancel()
+	slept, err = pd.Decelerate(ctx, "key1", 1000)
+	var decelerateErr *DecelerateError
+	assert.True(t, errors.As(err, &decelerateErr))
+	assert.True(t, errors.Is(err, ErrDecelerated))
+	assert.Equal(t, time.Duration(0), slept)
+}
+
+func waitForWaiters(t *testing.T, fc *clock.Fake, n int) {
+	assert.Eventually(t, func() bool { return fc.Waiters() == n }, time.Second, time.Millisecond)
+}
+
+type countingPressureHttpClient struct {
+	MockPressureHttpClient
+	batchCount int64
+}
+
+func (c *countingPressureHttpClient) BatchGetSleeptime(ctx context.Context, keys []string) (map[string]int32, error) {
+	atomic.AddInt64(&c.batchCount, 1)
+	return c.MockPressureHttpClient.BatchGetSleeptime(ctx, keys)
+}
+
+func TestPressureDeceleratorLifecycle(t *testing.T) {
+	ctx := context.Background()
+	conf := &PressureConfig{
+		MaxSleeptime:   500,
+		MaxKeyCapacity: 3,
+		EvictThreshold: 60 * 60 * 1000,
+		UpdateInterval: 60 * 60 * 1000,
+	}
+	cli := &countingPressureHttpClient{}
+	fc := clock.NewFake(time.Unix(0, 0))
+	pd := NewPressureDecelerator(ctx, conf, cli)
+	pd.SetClock(fc)
+	pd.GetSleeptime("key1")
+	assert.Equal(t, int64(0), atomic.LoadInt64(&cli.batchCount))
+
+	// 重复启动无副作用
+	pd.Start(ctx)
+	pd.Start(ctx)
+	waitForWaiters(t, fc, 1)
+	fc.Advance(time.Minute)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&cli.batchCount))
+
+	// 更新周期变更后重置定时器
+	newConf := *conf
+	newConf.UpdateInterval = 10
+	pd.setConfig(&newConf)
+	assert.Eventually(t, func() bool {
+		fc.Advance(10 * time.Millisecond)
+		return atomic.LoadInt64(&cli.batchCount) >= 1
+	}, time.Second, time.Millisecond)
+
+	// 停止后定时器释放，不再刷新
+	pd.Stop()
+	pd.Stop()
+	assert.Equal(t, 0, fc.Waiters())
+	count := atomic.LoadInt64(&cli.batchCount)
+	fc.Advance(time.Minute)
+	assert.Equal(t, count, atomic.LoadInt64(&cli.batchCount))
+
+	// 停止后可重新启动，使用最新的更新周期
+	pd.Start(ctx)
+	waitForWaiters(t, fc, 1)
+	fc.Advance(10 * time.Millisecond)
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&cli.batchCount) > count }, time.Second, time.Millisecond)
+	pd.Stop()
+
+	// ctx 结束后刷新任务退出，可重新启动
+	cancelCtx, cancel := context.WithCancel(ctx)
+	pd.Start(cancelCtx)
+	waitForWaiters(t, fc, 1)
+	cancel()
+	waitForWaiters(t, fc, 0)
+	pd.Start(ctx)
+	waitForWaiters(t, fc, 1)
+	pd.Stop()
+	assert.Equal(t, 0, fc.Waiters())
+}
+
+type stubPressureHttpClient struct {
+	lock   sync.Mutex
+	values map[string]int32
+	err    error
+}
+
+func (c *stubPressureHttpClient) set(values map[string]int32, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values, c.err = values, err
+}
+
+func (c *stubPressureHttpClient) GetSleeptime(ctx context.Context, key string) (int32, error) {
+	res, err := c.BatchGetSleeptime(ctx, []string{key})
+	return res[key], err
+}
+
+func (c *stubPressureHttpClient) BatchGetSleeptime(ctx context.Context, keys []string) (map[string]int32, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	res := make(map[string]int32, len(keys))
+	for _, key := range keys {
+		res[key] = c.values[key]
+	}
+	return res, nil
+}
+
+func TestPressureDeceleratorSmoothing(t *testing.T) {
+	conf := &PressureConfig{
+		MaxSleeptime:       1000,
+		MaxKeyCapacity:     3,
+		EvictThreshold:     60 * 1000,
+		UpdateInterval:     1000,
+		SmoothingFactor:    0.5,
+		MaxStepPerInterval: 300,
+		StaleThreshold:     3000,
+	}
+	cli := &stubPressureHttpClient{}
+	fc := clock.NewFake(time.Unix(0, 0))
+	pd := NewPressureDecelerator(context.Background(), conf, cli)
+	pd.SetClock(fc)
+
+	// 平滑上升：0 -> 300 -> 600（步长限制） -> 800（EWMA）
+	cli.set(map[string]int32{"key1": 1000}, nil)
+	assert.Equal(t, int32(300), pd.GetSleeptime("key1"))
+	fc.Advance(time.Second)
+	pd.update(context.Background())
+	assert.Equal(t, int32(600), pd.GetSleeptime("key1"))
+	fc.Advance(time.Second)
+	pd.update(context.Background())
+	assert.Equal(t, int32(800), pd.GetSleeptime("key1"))
+
+	// 拉取失败时保留上次成功的结果
+	cli.set(nil, errors.New("pressure center unavailable"))
+	fc.Advance(time.Second)
+	pd.update(context.Background())
+	assert.Equal(t, int32(800), pd.GetSleeptime("key1"))
+	state := pd.Snapshot().Keys[0]
+	assert.Equal(t, "key1", state.Key)
+	assert.Equal(t, int32(1000), state.RawSleeptime)
+	assert.Equal(t, int64(1), state.ErrorCount)
+	assert.Equal(t, "pressure center unavailable", state.LastError)
+	assert.Equal(t, int64(2000), state.LastUpdateTime)
+
+	// 超过 StaleThreshold 后清零
+	fc.Advance(3 * time.Second)
+	pd.update(context.Background())
+	assert.Equal(t, int32(0), pd.GetSleeptime("key1"))
+	assert.Equal(t, int64(2), pd.Snapshot().Keys[0].ErrorCount)
+
+	// 恢复后平滑下降
+	cli.set(map[string]int32{"key1": 0}, nil)
+	pd.UpdateSleeptimes(map[string]int32{"key1": 400})
+	fc.Advance(time.Second)
+	pd.update(context.Background())
+	assert.Equal(t, int32(200), pd.GetSleeptime("key1"))
+	assert.Equal(t, int64(0), pd.Snapshot().Keys[0].ErrorCount)
+}
+
+func TestPressureDeceleratorSnapshot(t *testing.T) {
+	conf := &PressureConfig{
+		MaxSleeptime:        1000,
+		MaxKeyCapacity:      3,
+		EvictThreshold:      2000,
+		UpdateInterval:      1000,
+		JitterRatio:         -1,
+		SnapshotLogInterval: -1,
+	}
+	cli := &stubPressureHttpClient{}
+	fc := clock.NewFake(time.Unix(0, 0))
+	pd := NewPressureDecelerator(context.Background(), conf, cli)
+	pd.SetClock(fc)
+
+	cli.set(map[string]int32{"key1": 100}, nil)
+	assert.Equal(t, int32(100), pd.GetSleeptime("key1"))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := pd.Decelerate(context.Background(), "key1", 100)
+		done <- err
+	}()
+	waitForWaiters(t, fc, 1)
+	fc.Advance(100 * time.Millisecond)
+	assert.NoError(t, <-done)
+
+	cli.set(nil, errors.New("pressure center unavailable"))
+	fc.Advance(time.Second)
+	pd.update(context.Background())
+
+	snapshot := pd.Snapshot()
+	assert.Equal(t, int64(1), snapshot.TrackedKeys)
+	assert.Equal(t, int64(2), snapshot.Polls)
+	assert.Equal(t, int64(1), snapshot.PollErrors)
+	assert.Equal(t, "pressure center unavailable", snapshot.LastPollError)
+	assert.Equal(t, int64(100), snapshot.Keys[0].TotalSleep)
+	assert.Equal(t, int64(1), snapshot.Keys[0].SleepCount)
+
+	// 超过 EvictThreshold 未访问的 key 被淘汰
+	fc.Advance(3 * time.Second)
+	pd.update(context.Background())
+	snapshot = pd.Snapshot()
+	assert.Equal(t, int64(0), snapshot.TrackedKeys)
+	assert.Equal(t, int64(1), snapshot.Evictions)
+}
+
+type ctxPressureHttpClient struct {
+	MockPressureHttpClient
+	started chan interface{}
+}
+
+func (c *ctxPressureHttpClient) GetSleeptime(ctx context.Context, key string) (int32, error) {
+	c.started <- ctx.Value(testCtxKey{})
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+type testCtxKey struct{}
+
+func TestPressureDeceleratorStopCancelsFetch(t *testing.T) {
+	conf := &PressureConfig{MaxSleeptime: 500, MaxKeyCapacity: 3, EvictThreshold: 2000, UpdateInterval: 60 * 1000, PushEnabled: true}
+	cli := &ctxPressureHttpClient{started: make(chan interface{}, 1)}
+	pd := NewPressureDecelerator(context.WithValue(context.Background(), testCtxKey{}, "tenant"), conf, cli)
+	pd.Start(context.Background())
+
+	// 拉取保留 pd ctx 中的值，Stop 时中断进行中的拉取
+	assert.Equal(t, int32(0), pd.GetSleeptime("key1"))
+	assert.Equal(t, "tenant", <-cli.started)
+	stopped := make(chan struct{})
+	go func() {
+		pd.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not cancel async fetch")
+	}
+
+	// Stop 后重新启动，请求路径不被阻塞
+	pd.Start(context.Background())
+	assert.Equal(t, int32(0), pd.GetSleeptime("key2"))
+	<-cli.started
+	pd.Stop()
+}
+
+func TestMarshalPressureEvent(t *testing.T) {
+	snapshot := &PressureSnapshot{TrackedKeys: 1000}
+	keys := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("tenant_%d_object_%d", i, i)
+		keys = append(keys, key)
+		snapshot.Keys = append(snapshot.Keys, PressureKeyState{Key: key, Sleeptime: int32(i), LastError: strings.Repeat("e", 500)})
+	}
+
+	// 快照保留 sleeptime 最大的 key 并记录总数，结果为合法 JSON 且不超过 LogLengthLimit
+	msg := marshalPressureEvent(&pressureEvent{Event: pressureEventSnapshot, Snapshot: snapshot})
+	assert.True(t, len(msg) <= utils.LogLengthLimit)
+	var event pressureEvent
+	assert.NoError(t, json.Unmarshal(msg, &event))
+	assert.Equal(t, 1000, event.TotalKeys)
+	assert.True(t, len(event.Snapshot.Keys) > 0 && len(event.Snapshot.Keys) <= pressureLogMaxKeys)
+	assert.Equal(t, int32(999), event.Snapshot.Keys[0].Sleeptime)
+	assert.Equal(t, 1000, len(snapshot.Keys))
+
+	msg = marshalPressureEvent(&pressureEvent{Event: pressureEventEvict, Keys: keys})
+	event = pressureEvent{}
+	assert.NoError(t, json.Unmarshal(msg, &event))
+	assert.Equal(t, pressureLogMaxKeys, len(event.Keys))
+	assert.Equal(t, 1000, event.TotalKeys)
+
+	// 未超出时不记录总数
+	msg = marshalPressureEvent(&pressureEvent{Event: pressureEventEvict, Keys: keys[:2]})
+	assert.NotContains(t, string(msg), "total_keys")
+}
@@ -3,7 +3,6 @@ package http
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"strings"
 
 	"github.com/byted-apaas/server-common-go/constants"
@@ -50,26 +49,33 @@ type BatchQueryPressureSignalResp struct {
 }
 
 func (c *PressureHttpClient) BatchGetSleeptime(ctx context.Context, keys []string) (map[string]int32, error) {
-	fmt.Printf("[pressure decelerator] keys: %+v\n", keys)
 	req := BatchQueryPressureSignalReq{
 		SignalList: keys,
 	}
 	ctx = utils.SetApiTimeoutMethodToCtx(ctx, constants.PressureSDK)
 	path := strings.ReplaceAll(BatchQueryPressureSignalPath, constants.ReplaceNamespace, utils.GetNamespaceFromCtx(ctx))
 	ctx = withPressureSdkReqTag(ctx)
+	ctx = utils.SetRequestPriorityToCtx(ctx, utils.RequestPriorityInfrastructure)
 	body, _, err := GetOpenapiClient().PostJson(ctx, path, nil, &req, AppTokenMiddleware, TenantAndUserMiddleware, ServiceIDMiddleware)
 	if err != nil {
-		fmt.Printf("BatchQueryPressureSignal PostJson error : %+v", err)
 		return nil, err
 	}
 	var resp BatchQueryPressureSignalResp
 	if err = json.Unmarshal(body, &resp); err != nil {
 		return nil, err
 	}
-	fmt.Printf("BatchQueryPressureSignal resp.PressureSignalMap : %+v\n", resp.Data.PressureSignalMap)
 	return resp.Data.PressureSignalMap, nil
 }
 
+// parsePressureSignals 解析响应头中推送的压力信号
+func parsePressureSignals(header string) (map[string]int32, error) {
+	signals := make(map[string]int32)
+	if err := json.Unmarshal([]byte(header), &signals); err != nil {
+		return nil, err
+	}
+	return signals, nil
+}
+
 // 带上反压中心请求tag，防止死循环
 func withPressureSdkReqTag(ctx context.Context) context.Context {
 	return context.WithValue(ctx, constants.CtxKeyPressureReqTag, true)
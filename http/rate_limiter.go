@@ -4,6 +4,14 @@ import (
 	"container/list"
 	"sync"
 	"time"
+
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+const (
+	// BatchRequestQuotaRatio 批量请求可使用的配额比例，剩余配额预留给交互请求
+	BatchRequestQuotaRatio = 0.8
 )
 
 var (
@@ -12,6 +20,7 @@ var (
 		maxRequest: -1,          // 默认不限流，在 AllowRequest 方法中实现
 		requests:   list.New(),
 		mutex:      sync.Mutex{},
+		clock:      clock.Default(),
 	}
 )
 
@@ -21,6 +30,14 @@ type RateLimiter struct {
 	maxRequest int
 	requests   *list.List
 	mutex      sync.Mutex
+	clock      clock.Clock
+}
+
+// SetClock 替换时钟，用于测试
+func (l *RateLimiter) SetClock(c clock.Clock) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.clock = c
 }
 
 func (l *RateLimiter) ResetRateLimiter(maxRequest int) bool {
@@ -35,14 +52,23 @@ func (l *RateLimiter) ResetRateLimiter(maxRequest int) bool {
 
 // AllowRequest 判断是否允许请求
 func (l *RateLimiter) AllowRequest() bool {
-	if l.maxRequest <= 0 { // 不限流
+	return l.AllowRequestWithPriority(utils.RequestPriorityInteractive)
+}
+
+// AllowRequestWithPriority 按优先级判断是否允许请求
+// - critical: 直接放行，不占用配额
+// - infrastructure: 直接放行，但占用配额，使业务请求感知到基础设施请求的压力
+// - interactive: 可使用全部配额
+// - batch: 只能使用 BatchRequestQuotaRatio 比例的配额，批量请求打满时交互请求仍有余量
+func (l *RateLimiter) AllowRequestWithPriority(priority utils.RequestPriority) bool {
+	if l.maxRequest <= 0 || priority == utils.RequestPriorityCritical { // 不限流
 		return true
 	}
 
 	l.mutex.Lock() // 加锁以保证并发安全
 	defer l.mutex.Unlock()
 
-	now := time.Now()
+	now := clock.OrDefault(l.clock).Now()
 	// 移除滑动窗口外的请求
 	for l.requests.Len() > 0 {
 		front := l.requests.Front()
@@ -53,11 +79,30 @@ func (l *RateLimiter) AllowRequest() bool {
 		}
 	}
 
-	// 如果请求数小于最大请求数，允许请求
-	if l.requests.Len() < l.maxRequest {
+	// 基础设施请求不会被拒绝，但需要记录到窗口中
+	if priority == utils.RequestPriorityInfrastructure {
+		l.requests.PushBack(now)
+		return true
+	}
+
+	// 如果请求数小于该优先级可用的最大请求数，允许请求
+	if l.requests.Len() < l.quotaOf(priority) {
 		l.requests.PushBack(now)
 		return true
 	}
 
 	return false
 }
+
+// quotaOf 获取优先级可使用的配额，批量请求至少保留 1 个配额
+func (l *RateLimiter) quotaOf(priority utils.RequestPriority) int {
+	if priority != utils.RequestPriorityBatch {
+		return l.maxRequest
+	}
+
+	quota := int(float64(l.maxRequest) * BatchRequestQuotaRatio)
+	if quota < 1 {
+		quota = 1
+	}
+	return quota
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"container/list"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+func TestRateLimiterPriority(t *testing.T) {
+	fc := clock.NewFake(time.Unix(0, 0))
+	l := &RateLimiter{
+		windowSize: time.Second,
+		maxRequest: 10,
+		requests:   list.New(),
+		clock:      fc,
+	}
+
+	// 批量请求只能使用 80% 的配额
+	for i := 0; i < 8; i++ {
+		assert.True(t, l.AllowRequestWithPriority(utils.RequestPriorityBatch))
+	}
+	assert.False(t, l.AllowRequestWithPriority(utils.RequestPriorityBatch))
+
+	// 交互请求仍可使用剩余配额
+	assert.True(t, l.AllowRequestWithPriority(utils.RequestPriorityInteractive))
+	assert.True(t, l.AllowRequest())
+	assert.False(t, l.AllowRequest())
+
+	// 配额耗尽后，基础设施请求与关键请求仍然放行
+	assert.True(t, l.AllowRequestWithPriority(utils.RequestPriorityInfrastructure))
+	assert.True(t, l.AllowRequestWithPriority(utils.RequestPriorityCritical))
+	assert.Equal(t, 11, l.requests.Len()) // 关键请求不占用配额
+
+	// 滑动窗口移出后恢复配额
+	fc.Advance(500 * time.Millisecond)
+	assert.False(t, l.AllowRequestWithPriority(utils.RequestPriorityBatch))
+	fc.Advance(501 * time.Millisecond)
+	assert.True(t, l.AllowRequestWithPriority(utils.RequestPriorityBatch))
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/byted-apaas/server-common-go/constants"
+	"github.com/byted-apaas/server-common-go/utils"
+)
+
+// loggerForAdapter 优先使用日志记录自带 ctx 中的 Logger，否则使用适配器创建时 ctx 中的 Logger
+func loggerForAdapter(recordCtx, ctx context.Context) *Logger {
+	if recordCtx != nil {
+		if l, ok := recordCtx.Value(constants.CtxKeyLogger).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return GetLogger(ctx)
+}
+
+// logWriter 将写入的每一行作为一条日志记录到 Logger
+type logWriter struct {
+	ctx   context.Context
+	level int
+
+	lock    sync.Mutex
+	pending []byte // 尚未换行的内容
+}
+
+// NewWriter 创建 io.Writer 适配器，用于 log.SetOutput 等场景，每行内容以 level 级别记录到 ctx 中的 Logger
+func NewWriter(ctx context.Context, level int) io.Writer {
+	return &logWriter{ctx: ctx, level: level}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	w.pending = append(w.pending, p...)
+	var lines []string
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, strings.TrimRight(string(w.pending[:i]), "\r"))
+		w.pending = w.pending[i+1:]
+	}
+	if len(w.pending) > utils.LogLengthLimit {
+		lines = append(lines, string(w.pending))
+		w.pending = nil
+	}
+	w.lock.Unlock()
+
+	l := loggerForAdapter(nil, w.ctx)
+	for _, line := range lines {
+		if line != "" {
+			l.logw(w.level, -1, line, nil)
+		}
+	}
+	return len(p), nil
+}
+
+// LogrusHook 将 logrus 日志记录到 ctx 中的 Logger，entry.Context 中有 Logger 时优先使用
+type LogrusHook struct {
+	ctx context.Context
+}
+
+func NewLogrusHook(ctx context.Context) *LogrusHook {
+	return &LogrusHook{ctx: ctx}
+}
+
+func (h *LogrusHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *LogrusHook) Fire(entry *logrus.Entry) error {
+	l := loggerForAdapter(entry.Context, h.ctx)
+	// 本地调试时 Logger 本身通过 logrus 输出到控制台，不再转发，避免循环
+	if l.root().isDebug {
+		return nil
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]Field, 0, len(keys))
+	for _, k := range keys {
+		if err, ok := entry.Data[k].(error); ok {
+			fields = append(fields, Field{Key: k, Value: err.Error()})
+			continue
+		}
+		fields = append(fields, Field{Key: k, Value: entry.Data[k]})
+	}
+	l.logw(logrusLevelToLogLevel(entry.Level), -1, entry.Message, fields)
+	return nil
+}
+
+func logrusLevelToLogLevel(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		return utils.LogLevelError
+	case logrus.WarnLevel:
+		return utils.LogLevelWarn
+	case logrus.InfoLevel:
+		return utils.LogLevelInfo
+	case logrus.DebugLevel:
+		return utils.LogLevelDebug
+	default:
+		return utils.LogLevelTrace
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package logger
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/byted-apaas/server-common-go/utils"
+)
+
+// DecompressForDeflate CompressForDeflate 的逆操作
+func DecompressForDeflate(s string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("decode base64 failed: %v", err)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("open zlib stream failed: %v", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompress failed: %v", err)
+	}
+	return data, nil
+}
+
+// LogBatchIssue 解码日志批次时发现的问题
+type LogBatchIssue struct {
+	Index    int    `json:"index"`    // 批次中的下标
+	Sequence int64  `json:"sequence"` // 日志序号，无法解析时为 0
+	Message  string `json:"message"`
+}
+
+// DecodedLogBatch 解码后的日志批次
+type DecodedLogBatch struct {
+	Logs   []Log           `json:"logs"`
+	Issues []LogBatchIssue `json:"issues,omitempty"`
+}
+
+// DecodeLogBatch 解码 SendLog 上报的 compressData，并检查序号缺口与被截断、丢弃的日志。
+// 入参可以是 compressData 本身，也可以是包含 compressData 字段的 JSON（如上报请求体、spool 文件）
+func DecodeLogBatch(payload string) (*DecodedLogBatch, error) {
+	compressData := strings.TrimSpace(payload)
+	if strings.HasPrefix(compressData, "{") {
+		var body struct {
+			CompressData string `json:"compressData"`
+		}
+		if err := json.Unmarshal([]byte(compressData), &body); err != nil {
+			return nil, fmt.Errorf("parse payload failed: %v", err)
+		}
+		compressData = body.CompressData
+	}
+	if compressData == "" {
+		return nil, fmt.Errorf("compressData is empty")
+	}
+
+	data, err := DecompressForDeflate(compressData)
+	if err != nil {
+		return nil, err
+	}
+	var items []string
+	if err = json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("parse log list failed: %v", err)
+	}
+
+	batch := &DecodedLogBatch{Logs: make([]Log, 0, len(items))}
+	var lastSeq int64
+	for i, item := range items {
+		var log Log
+		if err = utils.JsonUnmarshalBytes([]byte(item), &log); err != nil {
+			batch.Issues = append(batch.Issues, LogBatchIssue{Index: i, Message: fmt.Sprintf("invalid log entry, possibly truncated: %v", err)})
+			continue
+		}
+		batch.Logs = append(batch.Logs, log)
+
+		if lastSeq > 0 && log.Sequence != lastSeq+1 {
+			msg := fmt.Sprintf("sequence gap: expected %d, got %d", lastSeq+1, log.Sequence)
+			if log.Sequence <= lastSeq {
+				msg = fmt.Sprintf("sequence out of order: %d after %d", log.Sequence, lastSeq)
+			}
+			batch.Issues = append(batch.Issues, LogBatchIssue{Index: i, Sequence: log.Sequence, Message: msg})
+		}
+		lastSeq = log.Sequence
+
+		switch {
+		case strings.HasSuffix(log.Content, utils.LogLengthLimitTip):
+			batch.Issues = append(batch.Issues, LogBatchIssue{Index: i, Sequence: log.Sequence, Message: "content truncated: exceeds length limit"})
+		case log.Content == utils.LogCountLimitTip:
+			batch.Issues = append(batch.Issues, LogBatchIssue{Index: i, Sequence: log.Sequence, Message: "later logs discarded: exceeds count limit"})
+		}
+	}
+	return batch, nil
+}
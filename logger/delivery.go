@@ -0,0 +1,366 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package logger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/byted-apaas/server-common-go/http"
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+const (
+	DefaultDeliveryMaxRetries = 3
+	DefaultDeliveryBackoff    = 200 * time.Millisecond
+	DefaultMaxRetryDuration   = 2 * time.Second
+	DefaultResendTimeout      = time.Second
+	DefaultSpoolMaxBytes      = 50 * 1024 * 1024 // 50MB
+	DefaultSpoolMaxAge        = 24 * time.Hour
+
+	spoolFileSuffix  = ".spool"
+	spoolClaimSuffix = ".claimed" // 补发中的批次，通过原子重命名认领，避免多个进程重复补发
+)
+
+// DeliveryConfig 日志发送配置：临时错误按指数退避重试，失败后写入本地 spool 目录，在下次发送成功或启动时补发。
+// spool 按租户、命名空间与凭证分目录，补发时只发送与当前调用身份相同的批次
+type DeliveryConfig struct {
+	MaxRetries       int           // 最大重试次数，0 使用默认值，< 0 表示不重试
+	Backoff          time.Duration // 首次重试等待时长，之后每次翻倍，0 使用默认值
+	MaxRetryDuration time.Duration // 单个批次重试的总时长上限，超出后不再重试，0 使用默认值
+	ResendTimeout    time.Duration // 发送成功后补发 spool 的时长上限，0 使用默认值，< 0 表示只在 ResendSpooledLogs 时补发
+	SpoolDir         string        // spool 目录，为空时使用 os.TempDir()/apaas-log-spool
+	MaxSpoolBytes    int64         // 每个身份的 spool 目录大小上限，超出时淘汰最早的批次，0 使用默认值，< 0 表示不落盘
+	MaxSpoolAge      time.Duration // spool 批次最长保留时间，超出后丢弃，0 使用默认值
+}
+
+// DeliveryStats 日志发送指标
+type DeliveryStats struct {
+	Sent    int64 `json:"sent"`    // 发送成功的日志条数
+	Retries int64 `json:"retries"` // 重试次数
+	Spooled int64 `json:"spooled"` // 写入 spool 的日志条数
+	Resent  int64 `json:"resent"`  // 从 spool 补发成功的日志条数
+	Dropped int64 `json:"dropped"` // 丢弃的日志条数
+}
+
+type spoolEntry struct {
+	Identity     spoolIdentity `json:"identity"`
+	Count        int           `json:"count"`
+	CompressData string        `json:"compressData"`
+}
+
+// spoolIdentity 批次上报时使用的身份，补发时须与当前调用一致，避免以其他租户或凭证上报
+type spoolIdentity struct {
+	Tenant     string `json:"tenant"`
+	Namespace  string `json:"namespace"`
+	Credential string `json:"credential"`
+}
+
+// spoolIdentityFromCtx 获取 ctx 发起上报时使用的身份，凭证无法提供身份标识时返回 false
+func spoolIdentityFromCtx(ctx context.Context) (spoolIdentity, bool) {
+	credential, ok := http.GetCredentialIDFromCtx(ctx)
+	return spoolIdentity{Tenant: utils.GetTenantName(), Namespace: utils.GetNamespace(), Credential: credential}, ok
+}
+
+// dir 该身份的 spool 目录
+func (i spoolIdentity) dir(root string) string {
+	sum := sha256.Sum256([]byte(i.Tenant + "\x00" + i.Namespace + "\x00" + i.Credential))
+	return filepath.Join(root, hex.EncodeToString(sum[:8]))
+}
+
+type deliverer struct {
+	lock  sync.RWMutex
+	conf  DeliveryConfig
+	clock clock.Clock
+
+	spoolLock sync.Mutex // spool 目录读写互斥
+	resending int32
+
+	sent, retries, spooled, resent, dropped int64
+}
+
+var defaultDeliverer = newDeliverer(DeliveryConfig{})
+
+func newDeliverer(conf DeliveryConfig) *deliverer {
+	d := &deliverer{clock: clock.Default()}
+	d.setConfig(conf)
+	return d
+}
+
+// SetDeliveryConfig 修改日志发送配置
+func SetDeliveryConfig(conf DeliveryConfig) {
+	defaultDeliverer.setConfig(conf)
+}
+
+// GetDeliveryStats 获取日志发送指标
+func GetDeliveryStats() DeliveryStats {
+	return defaultDeliverer.stats()
+}
+
+// ResendSpooledLogs 补发 spool 目录中与 ctx 身份相同的日志，可在进程启动时调用
+func ResendSpooledLogs(ctx context.Context) {
+	defaultDeliverer.resendSpooled(ctx, sendLog)
+}
+
+func (d *deliverer) setConfig(conf DeliveryConfig) {
+	if conf.MaxRetries == 0 {
+		conf.MaxRetries = DefaultDeliveryMaxRetries
+	}
+	if conf.Backoff <= 0 {
+		conf.Backoff = DefaultDeliveryBackoff
+	}
+	if conf.MaxRetryDuration <= 0 {
+		conf.MaxRetryDuration = DefaultMaxRetryDuration
+	}
+	if conf.ResendTimeout == 0 {
+		conf.ResendTimeout = DefaultResendTimeout
+	}
+	if conf.SpoolDir == "" {
+		conf.SpoolDir = filepath.Join(os.TempDir(), "apaas-log-spool")
+	}
+	if conf.MaxSpoolBytes == 0 {
+		conf.MaxSpoolBytes = DefaultSpoolMaxBytes
+	}
+	if conf.MaxSpoolAge <= 0 {
+		conf.MaxSpoolAge = DefaultSpoolMaxAge
+	}
+	d.lock.Lock()
+	d.conf = conf
+	d.lock.Unlock()
+}
+
+func (d *deliverer) config() DeliveryConfig {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.conf
+}
+
+func (d *deliverer) stats() DeliveryStats {
+	return DeliveryStats{
+		Sent:    atomic.LoadInt64(&d.sent),
+		Retries: atomic.LoadInt64(&d.retries),
+		Spooled: atomic.LoadInt64(&d.spooled),
+		Resent:  atomic.LoadInt64(&d.resent),
+		Dropped: atomic.LoadInt64(&d.dropped),
+	}
+}
+
+// deliver 发送一个批次，失败后写入 spool；发送成功时在调用方 ctx 内补发 spool 中同一身份的批次，耗时不超过 ResendTimeout
+func (d *deliverer) deliver(ctx context.Context, compressData string, count int) error {
+	send := sendLog
+	err := d.sendWithRetry(ctx, send, compressData)
+	if err == nil {
+		atomic.AddInt64(&d.sent, int64(count))
+		if timeout := d.config().ResendTimeout; timeout > 0 {
+			resendCtx, cancel := context.WithTimeout(ctx, timeout)
+			d.resendSpooled(resendCtx, send)
+			cancel()
+		}
+		return nil
+	}
+
+	identity, ok := spoolIdentityFromCtx(ctx)
+	if !ok {
+		atomic.AddInt64(&d.dropped, int64(count))
+		return fmt.Errorf("send log failed: %v, credential without identity can not be spooled", err)
+	}
+	if spoolErr := d.spool(spoolEntry{Identity: identity, Count: count, CompressData: compressData}); spoolErr != nil {
+		atomic.AddInt64(&d.dropped, int64(count))
+		return fmt.Errorf("send log failed: %v, spool failed: %v", err, spoolErr)
+	}
+	atomic.AddInt64(&d.spooled, int64(count))
+	return fmt.Errorf("send log failed, spooled for resend: %v", err)
+}
+
+// sendWithRetry 发送批次，临时错误按指数退避重试，重试总时长不超过 MaxRetryDuration；4xx、鉴权失败等不可重试的错误直接返回
+func (d *deliverer) sendWithRetry(ctx context.Context, send func(context.Context, interface{}) error, compressData string) error {
+	conf := d.config()
+	clk := clock.OrDefault(d.clock)
+	start := clk.Now()
+	backoff := conf.Backoff
+	for attempt := 0; ; attempt++ {
+		err := send(ctx, map[string]string{"compressData": compressData})
+		if err == nil || !http.IsRetryableError(err) || attempt >= conf.MaxRetries || clk.Since(start)+backoff > conf.MaxRetryDuration {
+			return err
+		}
+		atomic.AddInt64(&d.retries, 1)
+
+		timer := clk.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C():
+		}
+		backoff *= 2
+	}
+}
+
+func (d *deliverer) spool(entry spoolEntry) error {
+	conf := d.config()
+	if conf.MaxSpoolBytes < 0 {
+		return fmt.Errorf("spool disabled")
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > conf.MaxSpoolBytes {
+		return fmt.Errorf("batch size %d exceeds spool limit %d", len(data), conf.MaxSpoolBytes)
+	}
+
+	dir := entry.Identity.dir(conf.SpoolDir)
+	d.spoolLock.Lock()
+	defer d.spoolLock.Unlock()
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	files, total := d.listSpool(dir)
+	for len(files) > 0 && total+int64(len(data)) > conf.MaxSpoolBytes {
+		d.dropSpoolFile(files[0].path)
+		total -= files[0].size
+		files = files[1:]
+	}
+
+	name := fmt.Sprintf("%020d-%d%s", clock.OrDefault(d.clock).Now().UnixNano(), os.Getpid(), spoolFileSuffix)
+	tmp := filepath.Join(dir, name+".tmp")
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, name))
+}
+
+// resendSpooled 按时间顺序补发 spool 中与 ctx 身份相同的批次，遇到临时错误或 ctx 结束即停止；
+// 过期批次与不可重试的批次直接丢弃。每个批次发送前通过原子重命名认领，多个进程共享目录时不会重复补发
+func (d *deliverer) resendSpooled(ctx context.Context, send func(context.Context, interface{}) error) {
+	if ctx.Err() != nil || !atomic.CompareAndSwapInt32(&d.resending, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&d.resending, 0)
+	defer utils.PanicGuard(ctx)
+
+	identity, ok := spoolIdentityFromCtx(ctx)
+	if !ok {
+		return
+	}
+	conf := d.config()
+	dir := identity.dir(conf.SpoolDir)
+	d.spoolLock.Lock()
+	files, _ := d.listSpool(dir)
+	d.spoolLock.Unlock()
+
+	now := clock.OrDefault(d.clock).Now()
+	d.dropStaleClaims(dir, now, conf.MaxSpoolAge)
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		if now.Sub(file.modTime) > conf.MaxSpoolAge {
+			d.spoolLock.Lock()
+			d.dropSpoolFile(file.path)
+			d.spoolLock.Unlock()
+			continue
+		}
+
+		claimed, ok := claimSpoolFile(file.path)
+		if !ok {
+			continue // 已被其他进程认领或淘汰
+		}
+		data, err := os.ReadFile(claimed)
+		if err != nil {
+			continue
+		}
+		var entry spoolEntry
+		if err = json.Unmarshal(data, &entry); err != nil || entry.Identity != identity {
+			_ = os.Remove(claimed)
+			continue
+		}
+		if err = send(ctx, map[string]string{"compressData": entry.CompressData}); err != nil {
+			if !http.IsRetryableError(err) {
+				_ = os.Remove(claimed)
+				atomic.AddInt64(&d.dropped, int64(entry.Count))
+				continue
+			}
+			_ = os.Rename(claimed, file.path) // 释放认领，下次补发
+			return
+		}
+		_ = os.Remove(claimed)
+		atomic.AddInt64(&d.resent, int64(entry.Count))
+	}
+}
+
+// claimSpoolFile 通过原子重命名认领批次，返回认领后的路径
+func claimSpoolFile(path string) (string, bool) {
+	claimed := path + spoolClaimSuffix
+	if err := os.Rename(path, claimed); err != nil {
+		return "", false
+	}
+	return claimed, true
+}
+
+// dropStaleClaims 丢弃认领后未完成的过期批次，如补发进程异常退出
+func (d *deliverer) dropStaleClaims(dir string, now time.Time, maxAge time.Duration) {
+	claims, _ := filepath.Glob(filepath.Join(dir, "*"+spoolFileSuffix+spoolClaimSuffix))
+	for _, path := range claims {
+		if info, err := os.Stat(path); err == nil && now.Sub(info.ModTime()) > maxAge {
+			d.spoolLock.Lock()
+			d.dropSpoolFile(path)
+			d.spoolLock.Unlock()
+		}
+	}
+}
+
+type spoolFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// listSpool 按文件名（写入时间）升序列出 spool 批次
+func (d *deliverer) listSpool(dir string) ([]spoolFile, int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0
+	}
+	var (
+		files []spoolFile
+		total int64
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), spoolFileSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, spoolFile{path: filepath.Join(dir, entry.Name()), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, total
+}
+
+// dropSpoolFile 丢弃 spool 批次并计入丢弃指标，调用方需持有 spoolLock
+func (d *deliverer) dropSpoolFile(path string) {
+	if data, err := os.ReadFile(path); err == nil {
+		var entry spoolEntry
+		if json.Unmarshal(data, &entry) == nil {
+			atomic.AddInt64(&d.dropped, int64(entry.Count))
+		}
+	}
+	_ = os.Remove(path)
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Field 结构化日志字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value.String()}
+}
+
+// Err 以 "error" 为 key 记录错误信息
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// Any 任意值，无法 json 序列化时（如 chan、func、NaN）以 fmt.Sprint 的结果记录
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// fieldsToMap 合并字段，同名字段以后出现的为准
+func fieldsToMap(base, fields []Field) map[string]interface{} {
+	if len(base) == 0 && len(fields) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(base)+len(fields))
+	for _, f := range base {
+		m[f.Key] = encodableValue(f.Value)
+	}
+	for _, f := range fields {
+		m[f.Key] = encodableValue(f.Value)
+	}
+	return m
+}
+
+// encodableValue 无法 json 序列化的值转为 fmt.Sprint 的结果，避免整条日志序列化失败
+func encodableValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil, string, bool, int, int32, int64, uint, uint32, uint64:
+		return v
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Sprint(value)
+		}
+		return v
+	case float32:
+		if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+			return fmt.Sprint(value)
+		}
+		return v
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+func formatFieldsForConsole(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, fields[k]))
+	}
+	return strings.Join(pairs, " ")
+}
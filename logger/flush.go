@@ -0,0 +1,116 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package logger
+
+import (
+	"context"
+	"time"
+
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+const (
+	DefaultFlushInterval  = 10 * time.Second
+	DefaultFlushBatchSize = 1000
+)
+
+// FlushConfig 后台增量发送配置
+type FlushConfig struct {
+	Interval  time.Duration // 定时发送间隔，0 使用默认值，< 0 表示不定时发送
+	BatchSize int           // 缓冲日志达到该条数时发送，0 使用默认值，< 0 表示不按条数发送
+}
+
+type autoFlusher struct {
+	batchSize int
+	trigger   chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func (f *autoFlusher) notify(pending int) {
+	if f.batchSize <= 0 || pending < f.batchSize {
+		return
+	}
+	select {
+	case f.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// StartAutoFlush 启动后台增量发送，适用于长时间运行的函数，避免进程退出时丢失全部日志并限制内存占用。
+// 日志序号在批次间连续，Send 时停止后台发送并追加聚合日志
+func (l *Logger) StartAutoFlush(ctx context.Context, conf FlushConfig) {
+	l = l.root()
+	if l.isDebug {
+		return
+	}
+	if conf.Interval == 0 {
+		conf.Interval = DefaultFlushInterval
+	}
+	if conf.BatchSize == 0 {
+		conf.BatchSize = DefaultFlushBatchSize
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.flusher != nil {
+		return
+	}
+	f := &autoFlusher{
+		batchSize: conf.BatchSize,
+		trigger:   make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	l.flusher = f
+	go l.runAutoFlush(ctx, f, conf.Interval)
+}
+
+func (l *Logger) runAutoFlush(ctx context.Context, f *autoFlusher, interval time.Duration) {
+	defer close(f.done)
+	defer utils.PanicGuard(ctx)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := clock.OrDefault(l.clock).NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-f.trigger:
+		}
+		if err := l.flush(ctx); err != nil {
+			utils.GetConsoleLogger(l.RequestID).Errorf("[Logger] flush failed, err: %v", err)
+		}
+	}
+}
+
+func (l *Logger) stopAutoFlush() {
+	l.lock.Lock()
+	f := l.flusher
+	l.flusher = nil
+	l.lock.Unlock()
+	if f == nil {
+		return
+	}
+	close(f.stop)
+	<-f.done
+}
+
+// Flush 立即发送当前缓冲的日志
+func (l *Logger) Flush(ctx context.Context) error {
+	l = l.root()
+	if l.isDebug {
+		return nil
+	}
+	return l.flush(ctx)
+}
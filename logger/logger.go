@@ -18,6 +18,7 @@ import (
 	"github.com/byted-apaas/server-common-go/http"
 	"github.com/byted-apaas/server-common-go/structs"
 	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
 )
 
 const (
@@ -43,6 +44,8 @@ type ExtraInfo struct {
 	ObjectLabel       structs.I18n `json:"objectLabel"`
 	TriggerTimeCost   int64        `json:"triggerTimeCost"`
 	RuntimeCost       int64        `json:"runtimeCost"`
+
+	SuppressedLogs []SuppressedLog `json:"suppressedLogs,omitempty"` // 被采样丢弃的日志统计
 }
 
 type Log struct {
@@ -58,6 +61,8 @@ type Log struct {
 	Tags      []Tag     `json:"tags"`
 	TagsI18n  []I18nTag `json:"tagsI18n"`
 	ExtraInfo ExtraInfo `json:"extraInfo"`
+
+	Fields map[string]interface{} `json:"fields,omitempty"` // 结构化字段
 }
 
 type Logger struct {
@@ -80,6 +85,23 @@ type Logger struct {
 	sequence         int64
 	isDebug          bool
 	streamLogCount   int64
+	clock            clock.Clock
+	level            int32 // 最低输出级别，数值大于 level 的日志不输出
+
+	parent *Logger // With 创建的子 logger 指向父 logger，日志写入根 logger
+	fields []Field // With 附加的结构化字段
+
+	recorded  int64      // 已记录的普通日志条数（含已发送），用于 LogCountLimit 限制
+	flushLock sync.Mutex // 保证批次按顺序发送
+	flusher   *autoFlusher
+
+	sinkLock sync.RWMutex
+	sinks    []Sink // AddSink 追加的 sink，写时复制
+
+	samplerLock sync.RWMutex
+	sampler     *sampler // nil 表示不采样
+
+	redactor *utils.Redactor // nil 表示不脱敏
 }
 
 func NewLogger(ctx context.Context) *Logger {
@@ -94,13 +116,17 @@ func NewLogger(ctx context.Context) *Logger {
 		tenantType:       utils.GetTenantTypeFromCtx(ctx),
 		startTriggerTime: getFunctionLoggerExtraToCtx(ctx).StartTriggerTime,
 
-		startRuntime:   time.Now().UnixNano() / int64(time.Millisecond),
+		startRuntime:   TimeNowMils(),
 		errorNum:       0,
 		infoNum:        0,
 		warnNum:        0,
 		isDebug:        utils.GetDebugTypeFromCtx(ctx) != 0,
 		sequence:       1,
 		streamLogCount: 0,
+		clock:          clock.Default(),
+		level:          int32(utils.GetLogLevelFromCtx(ctx)),
+		sampler:        newSampler(getDefaultSamplingConfig()),
+		redactor:       utils.GetRedactorFromCtx(ctx),
 	}
 
 	if !l.isDebug {
@@ -116,9 +142,50 @@ func NewConsoleLogger(ctx context.Context) *Logger {
 	return &Logger{
 		RequestID: utils.GetLogIDFromCtx(ctx),
 		isDebug:   true,
+		level:     int32(utils.GetLogLevelFromCtx(ctx)),
+		sampler:   newSampler(getDefaultSamplingConfig()),
+		redactor:  utils.GetRedactorFromCtx(ctx),
 	}
 }
 
+// SetLevel 运行时修改最低输出级别，取值同 utils.LogLevelXxx
+func (l *Logger) SetLevel(level int) {
+	atomic.StoreInt32(&l.root().level, int32(level))
+}
+
+// GetLevel 获取最低输出级别，未设置（如零值 Logger）时为 LogLevelInfo
+func (l *Logger) GetLevel() int {
+	level := int(atomic.LoadInt32(&l.root().level))
+	if level <= 0 {
+		return utils.LogLevelInfo
+	}
+	return level
+}
+
+// Enabled 判断该级别的日志是否输出
+func (l *Logger) Enabled(level int) bool {
+	return level <= l.GetLevel()
+}
+
+// SetClock 替换时钟，并以新时钟的当前时间作为运行开始时间，用于测试
+func (l *Logger) SetClock(c clock.Clock) {
+	l.clock = clock.OrDefault(c)
+	l.startRuntime = l.nowMils()
+}
+
+func (l *Logger) now() time.Time {
+	return clock.OrDefault(l.clock).Now()
+}
+
+func (l *Logger) nowMils() int64 {
+	return l.now().UnixNano() / int64(time.Millisecond)
+}
+
+func (l *Logger) nowMicros() *int64 {
+	t := l.now().UnixNano() / int64(time.Microsecond)
+	return &t
+}
+
 func SetLogger(ctx context.Context, l *Logger) context.Context {
 	return context.WithValue(ctx, constants.CtxKeyLogger, l)
 }
@@ -134,100 +201,201 @@ func GetLogger(ctx context.Context) *Logger {
 	return l
 }
 
-func (l *Logger) Infof(format string, args ...interface{}) {
-	if !l.isDebug {
-		atomic.AddInt64(&l.infoNum, 1)
-		l.addLog(fmt.Sprintf(format, args...), utils.LogLevelInfo, NormalLog)
-		l.streamLog(utils.LogLevelInfo, format, args...)
-	} else {
-		utils.GetConsoleLogger().Infof(format, args...)
+// With 创建附加结构化字段的子 logger，子 logger 与父 logger 共享日志缓冲、计数与级别
+func (l *Logger) With(fields ...Field) *Logger {
+	merged := make([]Field, 0, len(l.fields)+len(fields))
+	merged = append(merged, l.fields...)
+	merged = append(merged, fields...)
+	return &Logger{RequestID: l.RequestID, parent: l.root(), fields: merged}
+}
+
+func (l *Logger) root() *Logger {
+	for l.parent != nil {
+		l = l.parent
 	}
+	return l
+}
+
+func (l *Logger) Tracef(format string, args ...interface{}) {
+	l.logf(utils.LogLevelTrace, 1, format, args...)
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(utils.LogLevelDebug, 1, format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(utils.LogLevelInfo, 1, format, args...)
 }
 
 func (l *Logger) Warnf(format string, args ...interface{}) {
-	if !l.isDebug {
-		atomic.AddInt64(&l.warnNum, 1)
-		l.addLog(fmt.Sprintf(format, args...), utils.LogLevelWarn, NormalLog)
-		l.streamLog(utils.LogLevelWarn, format, args...)
-	} else {
-		utils.GetConsoleLogger().Warnf(format, args...)
-	}
+	l.logf(utils.LogLevelWarn, 1, format, args...)
 }
 
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	if !l.isDebug {
+	l.logf(utils.LogLevelError, 1, format, args...)
+}
+
+func (l *Logger) Tracew(msg string, fields ...Field) {
+	l.logw(utils.LogLevelTrace, 1, msg, fields)
+}
+
+func (l *Logger) Debugw(msg string, fields ...Field) {
+	l.logw(utils.LogLevelDebug, 1, msg, fields)
+}
+
+func (l *Logger) Infow(msg string, fields ...Field) {
+	l.logw(utils.LogLevelInfo, 1, msg, fields)
+}
+
+func (l *Logger) Warnw(msg string, fields ...Field) {
+	l.logw(utils.LogLevelWarn, 1, msg, fields)
+}
+
+func (l *Logger) Errorw(msg string, fields ...Field) {
+	l.logw(utils.LogLevelError, 1, msg, fields)
+}
+
+// logf 记录格式化日志，callerSkip 为入口函数到用户代码的栈帧数，用于统计被采样丢弃日志的调用位置，< 0 表示调用位置未知
+func (l *Logger) logf(level, callerSkip int, format string, args ...interface{}) {
+	// 低于最低级别的日志不格式化、不计数
+	if !l.Enabled(level) || !l.sampled(format, callerSkip) {
+		return
+	}
+	l.root().output(level, fmt.Sprintf(format, args...), fieldsToMap(l.fields, nil))
+}
+
+// logw 记录结构化日志，callerSkip 同 logf
+func (l *Logger) logw(level, callerSkip int, msg string, fields []Field) {
+	if !l.Enabled(level) || !l.sampled(msg, callerSkip) {
+		return
+	}
+	l.root().output(level, msg, fieldsToMap(l.fields, fields))
+}
+
+func (l *Logger) output(level int, msg string, fields map[string]interface{}) {
+	// 脱敏后再写入平台日志、标准输出与 sink
+	msg, fields = l.redactor.Redact(msg), l.redactor.RedactFields(fields)
+	l.writeSinks(level, msg, fields)
+
+	if l.isDebug {
+		if len(fields) > 0 {
+			msg = msg + " " + formatFieldsForConsole(fields)
+		}
+		console := utils.GetConsoleLogger()
+		switch level {
+		case utils.LogLevelError:
+			console.Errorf("%s", msg)
+		case utils.LogLevelWarn:
+			console.Warnf("%s", msg)
+		case utils.LogLevelInfo:
+			console.Infof("%s", msg)
+		case utils.LogLevelDebug:
+			console.Debugf("%s", msg)
+		default:
+			console.Tracef("%s", msg)
+		}
+		return
+	}
+
+	switch level {
+	case utils.LogLevelError:
 		atomic.AddInt64(&l.errorNum, 1)
-		l.addLog(fmt.Sprintf(format, args...), utils.LogLevelError, NormalLog)
-		l.streamLog(utils.LogLevelError, format, args...)
-	} else {
-		utils.GetConsoleLogger().Errorf(format, args...)
+	case utils.LogLevelWarn:
+		atomic.AddInt64(&l.warnNum, 1)
+	case utils.LogLevelInfo:
+		atomic.AddInt64(&l.infoNum, 1)
 	}
+	l.addLog(msg, level, NormalLog, fields)
+	l.streamLog(level, msg, fields)
 }
 
-func (l *Logger) streamLog(level int, format string, args ...interface{}) {
-	if l.streamLogCount > utils.LogCountLimit {
+func (l *Logger) streamLog(level int, msg string, fields map[string]interface{}) {
+	count := atomic.AddInt64(&l.streamLogCount, 1)
+	if count > utils.LogCountLimit {
 		return
 	}
 
-	l.streamLogCount++
+	userLog := l.newFormatLog(level, msg, fields)
+	if count == utils.LogCountLimit {
+		userLog.Message = utils.LogCountLimitTip
+	}
+
+	fmt.Println(userLog.String())
 
-	userLog := &utils.FormatLog{
+	return
+}
+
+func (l *Logger) newFormatLog(level int, msg string, fields map[string]interface{}) *utils.FormatLog {
+	return &utils.FormatLog{
 		Level:         level,
 		EventID:       l.executeID,
 		FunctionAPIID: l.functionAPIID,
 		LogID:         l.RequestID,
-		Timestamp:     time.Now().UnixNano() / 1e3, // 使用微秒
-		Message:       fmt.Sprintf(format, args...),
+		Timestamp:     l.now().UnixNano() / 1e3, // 使用微秒
+		Message:       msg,
 		TenantID:      l.tenantID,
 		TenantType:    l.tenantType,
 		Namespace:     l.namespace,
 		LogType:       constants.UserLogType,
+		Fields:        fields,
 	}
-
-	if l.streamLogCount == utils.LogCountLimit {
-		userLog.Message = utils.LogCountLimitTip
-	}
-
-	fmt.Println(userLog.String())
-
-	return
 }
 
+// Send 停止后台刷新，追加聚合日志并发送剩余日志，在函数调用结束时调用
 func Send(ctx context.Context, l *Logger) {
+	l = l.root()
 	if l.isDebug {
 		return
 	}
-	var (
-		err         error
-		data        []byte
-		compressLog string
-	)
-	defer func() {
-		if err != nil {
-			utils.GetConsoleLogger(l.RequestID).Errorf("[Logger] Send failed, err: %v", err)
-		}
-	}()
+	l.stopAutoFlush()
 
-	if len(l.logs) == 0 {
+	if atomic.LoadInt64(&l.recorded) == 0 {
 		return
 	}
-	l.addLog("", utils.LogLevelInfo, AggregationLog)
+	l.addLog("", utils.LogLevelInfo, AggregationLog, nil)
+
+	if err := l.flush(ctx); err != nil {
+		utils.GetConsoleLogger(l.RequestID).Errorf("[Logger] Send failed, err: %v", err)
+	}
+}
+
+// sendLog 发送日志批次，测试中可替换
+var sendLog = http.SendLog
+
+// flush 取出当前缓冲的日志并发送，发送期间的 addLog 写入新缓冲，发送失败时重试并写入 spool
+func (l *Logger) flush(ctx context.Context) error {
+	l.flushLock.Lock()
+	defer l.flushLock.Unlock()
+
+	l.lock.Lock()
+	batch := l.logs
+	l.logs = make([]string, 0, len(batch))
+	l.lock.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
 
-	data, err = utils.JsonMarshalBytes(l.logs)
+	data, err := utils.JsonMarshalBytes(batch)
 	if err != nil {
-		return
+		atomic.AddInt64(&defaultDeliverer.dropped, int64(len(batch)))
+		return err
 	}
-	compressLog, err = CompressForDeflate(data)
+	compressLog, err := CompressForDeflate(data)
 	if err != nil {
-		return
+		atomic.AddInt64(&defaultDeliverer.dropped, int64(len(batch)))
+		return err
 	}
-
-	err = http.SendLog(ctx, map[string]string{"compressData": compressLog})
+	return defaultDeliverer.deliver(ctx, compressLog, len(batch))
 }
 
-func (l *Logger) addLog(content string, level int, logType int) {
-	if logType == NormalLog && len(l.logs) >= utils.LogCountLimit {
-		return
+func (l *Logger) addLog(content string, level int, logType int, fields map[string]interface{}) {
+	var count int64
+	if logType == NormalLog {
+		if count = atomic.AddInt64(&l.recorded, 1); count > utils.LogCountLimit {
+			return
+		}
 	}
 
 	if len(content) > utils.LogLengthLimit {
@@ -239,18 +407,18 @@ func (l *Logger) addLog(content string, level int, logType int) {
 		RequestID:       l.RequestID,
 		Type:            logType,
 		Level:           level,
-		CreateTime:      TimeNowMils(),
-		CreateTimeMicro: TimeNowMicros(), // 用于旧日志转发到可观测
-		Sequence:        l.getSequence(),
+		CreateTime:      l.nowMils(),
+		CreateTimeMicro: l.nowMicros(), // 用于旧日志转发到可观测
 		Content:         content,
 		Tags:            make([]Tag, 0),
 		TagsI18n:        make([]I18nTag, 0),
 		ExtraInfo:       ExtraInfo{},
+		Fields:          fields,
 	}
 
 	// 聚合日志
 	if logType == AggregationLog {
-		curTime := TimeNowMils()
+		curTime := l.nowMils()
 		log.Tags = l.tags
 		log.TagsI18n = l.tagsI18n
 		log.ExtraInfo = l.extraInfo
@@ -263,24 +431,34 @@ func (l *Logger) addLog(content string, level int, logType int) {
 		if log.ExtraInfo.TriggerTimeCost <= 0 {
 			log.ExtraInfo.TriggerTimeCost = log.ExtraInfo.RuntimeCost
 		}
-		b, _ := utils.JsonMarshalBytes(log)
-		l.lock.Lock()
-		l.logs = append(l.logs, string(b))
-		l.lock.Unlock()
+		l.appendLog(&log)
 		return
 	}
 
-	if len(l.logs) < utils.LogCountLimit {
-		if len(l.logs) == utils.LogCountLimit-1 {
-			log.Content = utils.LogCountLimitTip
-		}
-		b, _ := utils.JsonMarshalBytes(log)
-		l.lock.Lock()
-		l.logs = append(l.logs, string(b))
-		l.lock.Unlock()
+	if count == utils.LogCountLimit {
+		log.Content = utils.LogCountLimitTip
+	}
+	pending, flusher := l.appendLog(&log)
+	if flusher != nil {
+		flusher.notify(pending)
 	}
 }
 
+// appendLog 在锁内分配序号并写入缓冲，保证缓冲与各发送批次中的序号有序且连续
+func (l *Logger) appendLog(log *Log) (int, *autoFlusher) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	log.Sequence = l.getSequence()
+	b, err := utils.JsonMarshalBytes(log)
+	if err != nil { // 字段无法序列化时去掉字段重新序列化，不丢弃日志内容
+		log.Fields = nil
+		log.Tags = append(log.Tags, Tag{Key: "logError", Value: err.Error()})
+		b, _ = utils.JsonMarshalBytes(log)
+	}
+	l.logs = append(l.logs, string(b))
+	return len(l.logs), l.flusher
+}
+
 func (l *Logger) getTags(ctx context.Context) []Tag {
 	return []Tag{
 		{
@@ -354,6 +532,16 @@ func (l *Logger) tagsAddNum(log Log) Log {
 		Value: strconv.FormatInt(l.errorNum, 10),
 	})
 
+	if s := l.getSampler(); s != nil {
+		if items, total := s.summary(); total > 0 {
+			log.Tags = append(log.Tags, Tag{
+				Key:   "suppressedNum",
+				Value: strconv.FormatInt(total, 10),
+			})
+			log.ExtraInfo.SuppressedLogs = items
+		}
+	}
+
 	return log
 }
 
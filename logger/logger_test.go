@@ -0,0 +1,647 @@
+package logger
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	stdlog "log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/byted-apaas/server-common-go/http"
+	"github.com/byted-apaas/server-common-go/utils"
+	"github.com/byted-apaas/server-common-go/utils/clock"
+)
+
+type countingStringer struct {
+	count int
+}
+
+func (s *countingStringer) String() string {
+	s.count++
+	return "value"
+}
+
+func TestLoggerLevel(t *testing.T) {
+	ctx := utils.SetLogLevelToCtx(context.Background(), utils.LogLevelDebug)
+	l := NewLogger(ctx)
+	assert.Equal(t, utils.LogLevelDebug, l.GetLevel())
+
+	arg := &countingStringer{}
+	l.Tracef("trace %s", arg)
+	l.Debugf("debug %s", arg)
+	l.Infof("info %s", arg)
+	assert.Equal(t, 2, arg.count) // trace 未格式化
+	assert.Equal(t, 2, len(l.logs))
+
+	// 运行时调整级别
+	l.SetLevel(utils.LogLevelWarn)
+	l.Infof("info %s", arg)
+	l.Warnf("warn %s", arg)
+	l.Errorf("error %s", arg)
+	assert.Equal(t, 4, arg.count)
+	assert.Equal(t, int64(1), l.infoNum)
+	assert.Equal(t, int64(1), l.warnNum)
+	assert.Equal(t, int64(1), l.errorNum)
+
+	// 默认级别为 info
+	assert.Equal(t, utils.LogLevelInfo, NewLogger(context.Background()).GetLevel())
+}
+
+func TestLoggerZeroValueLevel(t *testing.T) {
+	l := &Logger{}
+	assert.Equal(t, utils.LogLevelInfo, l.GetLevel())
+	assert.True(t, l.Enabled(utils.LogLevelError))
+	assert.False(t, l.Enabled(utils.LogLevelDebug))
+
+	l.Debugf("debug")
+	l.Infof("info")
+	l.Errorf("error")
+	assert.Equal(t, 2, len(l.logs))
+	assert.Equal(t, int64(1), l.infoNum)
+	assert.Equal(t, int64(1), l.errorNum)
+}
+
+func TestLoggerFields(t *testing.T) {
+	l := NewLogger(context.Background())
+	child := l.With(String("orderID", "o1"), Int("step", 1))
+
+	child.Infow("created", Int("step", 2), Bool("paid", true))
+	child.Warnf("retry %d", 1)
+	l.Infof("plain")
+
+	assert.Equal(t, 3, len(l.logs))
+	assert.Equal(t, int64(2), l.infoNum)
+	assert.Equal(t, int64(1), l.warnNum)
+
+	var log Log
+	assert.NoError(t, utils.JsonUnmarshalBytes([]byte(l.logs[0]), &log))
+	assert.Equal(t, "created", log.Content)
+	assert.Equal(t, map[string]interface{}{"orderID": "o1", "step": json.Number("2"), "paid": true}, log.Fields)
+	assert.NoError(t, utils.JsonUnmarshalBytes([]byte(l.logs[1]), &log))
+	assert.Equal(t, "retry 1", log.Content)
+	assert.Equal(t, "o1", log.Fields["orderID"])
+	log = Log{}
+	assert.NoError(t, utils.JsonUnmarshalBytes([]byte(l.logs[2]), &log))
+	assert.Nil(t, log.Fields)
+
+	// 子 logger 共享级别
+	child.SetLevel(utils.LogLevelWarn)
+	assert.Equal(t, utils.LogLevelWarn, l.GetLevel())
+}
+
+func TestLoggerUnencodableFields(t *testing.T) {
+	l := NewLogger(context.Background())
+	l.Infow("unencodable", Float64("nan", math.NaN()), Float64("inf", math.Inf(1)), Any("ch", make(chan int)),
+		Any("fn", func() {}), Any("nested", map[string]interface{}{"v": math.NaN()}), Int("ok", 1))
+
+	var log Log
+	assert.NoError(t, utils.JsonUnmarshalBytes([]byte(l.logs[0]), &log))
+	assert.Equal(t, "unencodable", log.Content)
+	assert.Equal(t, "NaN", log.Fields["nan"])
+	assert.Equal(t, "+Inf", log.Fields["inf"])
+	assert.IsType(t, "", log.Fields["ch"])
+	assert.IsType(t, "", log.Fields["fn"])
+	assert.Equal(t, "map[v:NaN]", log.Fields["nested"])
+	assert.Equal(t, json.Number("1"), log.Fields["ok"])
+
+	// 字段仍无法序列化时去掉字段，保留日志内容
+	l.addLog("raw", utils.LogLevelInfo, NormalLog, map[string]interface{}{"ch": make(chan int)})
+	log = Log{}
+	assert.NoError(t, utils.JsonUnmarshalBytes([]byte(l.logs[1]), &log))
+	assert.Equal(t, "raw", log.Content)
+	assert.Nil(t, log.Fields)
+	assert.Equal(t, "logError", log.Tags[len(log.Tags)-1].Key)
+}
+
+type capturedBatches struct {
+	lock    sync.Mutex
+	batches [][]Log
+}
+
+func (c *capturedBatches) send(ctx context.Context, data interface{}) error {
+	raw, _ := base64.StdEncoding.DecodeString(data.(map[string]string)["compressData"])
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	plain, _ := io.ReadAll(r)
+	var items []string
+	if err = json.Unmarshal(plain, &items); err != nil {
+		return err
+	}
+	batch := make([]Log, len(items))
+	for i, item := range items {
+		_ = json.Unmarshal([]byte(item), &batch[i])
+	}
+	c.lock.Lock()
+	c.batches = append(c.batches, batch)
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *capturedBatches) count() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.batches)
+}
+
+func TestLoggerAutoFlush(t *testing.T) {
+	captured := &capturedBatches{}
+	origin := sendLog
+	sendLog = captured.send
+	defer func() { sendLog = origin }()
+	useTestDeliverer(t, DeliveryConfig{})
+
+	l := NewLogger(context.Background())
+	l.StartAutoFlush(context.Background(), FlushConfig{Interval: -1, BatchSize: 3})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Infof("log")
+		}()
+	}
+	wg.Wait()
+	assert.Eventually(t, func() bool { return captured.count() == 1 }, time.Second, time.Millisecond)
+
+	l.Warnf("tail")
+	Send(context.Background(), l)
+	assert.Equal(t, 2, captured.count())
+
+	// 批次间序号连续，最后一条为聚合日志
+	var logs []Log
+	for _, batch := range captured.batches {
+		logs = append(logs, batch...)
+	}
+	assert.Equal(t, 5, len(logs))
+	for i, log := range logs {
+		if i > 0 {
+			assert.Equal(t, logs[i-1].Sequence+1, log.Sequence)
+		}
+	}
+	assert.Equal(t, AggregationLog, logs[4].Type)
+	assert.Equal(t, 0, len(l.logs))
+}
+
+func useTestDeliverer(t *testing.T, conf DeliveryConfig) {
+	conf.SpoolDir = t.TempDir()
+	origin := defaultDeliverer
+	defaultDeliverer = newDeliverer(conf)
+	t.Cleanup(func() { defaultDeliverer = origin })
+}
+
+func spoolFiles(t *testing.T) []string {
+	files, err := filepath.Glob(filepath.Join(defaultDeliverer.config().SpoolDir, "*", "*"+spoolFileSuffix))
+	assert.Nil(t, err)
+	return files
+}
+
+func TestLoggerDelivery(t *testing.T) {
+	captured := &capturedBatches{}
+	var (
+		lock     sync.Mutex
+		attempts int
+		failing  = true
+	)
+	origin := sendLog
+	sendLog = func(ctx context.Context, data interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		if failing {
+			return errors.New("unavailable")
+		}
+		return captured.send(ctx, data)
+	}
+	defer func() { sendLog = origin }()
+	useTestDeliverer(t, DeliveryConfig{MaxRetries: 2, Backoff: time.Millisecond})
+
+	// 重试耗尽后写入 spool
+	l := NewLogger(context.Background())
+	l.Infof("first")
+	Send(context.Background(), l)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 1, len(spoolFiles(t)))
+	assert.Equal(t, DeliveryStats{Retries: 2, Spooled: 2}, GetDeliveryStats())
+
+	// ctx 已结束时不补发
+	lock.Lock()
+	failing = false
+	lock.Unlock()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ResendSpooledLogs(cancelled)
+	assert.Equal(t, 1, len(spoolFiles(t)))
+
+	// 下次发送成功后在调用方 ctx 内补发 spool 中的批次
+	l = NewLogger(context.Background())
+	l.Infof("second")
+	Send(context.Background(), l)
+	assert.Equal(t, int64(2), GetDeliveryStats().Resent)
+	assert.Equal(t, 0, len(spoolFiles(t)))
+	assert.Equal(t, int64(2), GetDeliveryStats().Sent)
+	assert.Equal(t, 2, captured.count())
+	assert.Equal(t, "second", captured.batches[0][0].Content)
+	assert.Equal(t, "first", captured.batches[1][0].Content)
+}
+
+func TestLoggerDeliveryIdentity(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		failing = true
+		tokens  []string
+	)
+	origin := sendLog
+	sendLog = func(ctx context.Context, data interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if failing {
+			return errors.New("unavailable")
+		}
+		id, _ := http.GetCredentialIDFromCtx(ctx)
+		tokens = append(tokens, id)
+		return nil
+	}
+	defer func() { sendLog = origin }()
+	useTestDeliverer(t, DeliveryConfig{MaxRetries: -1})
+
+	ctxA := http.SetCredentialToCtx(context.Background(), http.NewStaticCredential("token-a"))
+	ctxB := http.SetCredentialToCtx(context.Background(), http.NewStaticCredential("token-b"))
+	l := NewLogger(ctxA)
+	l.Infof("a")
+	Send(ctxA, l)
+	assert.Equal(t, 1, len(spoolFiles(t)))
+
+	// 其他凭证发送成功时不补发该批次
+	lock.Lock()
+	failing = false
+	lock.Unlock()
+	l = NewLogger(ctxB)
+	l.Infof("b")
+	Send(ctxB, l)
+	assert.Equal(t, int64(0), GetDeliveryStats().Resent)
+	assert.Equal(t, 1, len(spoolFiles(t)))
+
+	// 同一凭证补发
+	ResendSpooledLogs(ctxA)
+	assert.Equal(t, int64(2), GetDeliveryStats().Resent)
+	assert.Equal(t, 0, len(spoolFiles(t)))
+	idA, _ := http.GetCredentialIDFromCtx(ctxA)
+	idB, _ := http.GetCredentialIDFromCtx(ctxB)
+	assert.Equal(t, []string{idB, idA}, tokens)
+}
+
+func TestLoggerDeliveryNonRetryable(t *testing.T) {
+	var attempts int64
+	origin := sendLog
+	sendLog = func(ctx context.Context, data interface{}) error {
+		atomic.AddInt64(&attempts, 1)
+		return http.NonRetryableError(errors.New("forbidden"))
+	}
+	defer func() { sendLog = origin }()
+	useTestDeliverer(t, DeliveryConfig{MaxRetries: 2, Backoff: time.Millisecond})
+
+	// 不可重试的错误不重试
+	l := NewLogger(context.Background())
+	l.Infof("log")
+	Send(context.Background(), l)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&attempts))
+	assert.Equal(t, int64(0), GetDeliveryStats().Retries)
+	files := spoolFiles(t)
+	assert.Equal(t, 1, len(files))
+
+	// 已被其他进程认领的批次不再补发
+	claimed, ok := claimSpoolFile(files[0])
+	assert.True(t, ok)
+	_, ok = claimSpoolFile(files[0])
+	assert.False(t, ok)
+	ResendSpooledLogs(context.Background())
+	assert.Equal(t, int64(1), atomic.LoadInt64(&attempts))
+	assert.Nil(t, os.Rename(claimed, files[0]))
+
+	// 补发时遇到不可重试的错误丢弃该批次
+	ResendSpooledLogs(context.Background())
+	assert.Equal(t, int64(2), atomic.LoadInt64(&attempts))
+	assert.Equal(t, 0, len(spoolFiles(t)))
+	assert.Equal(t, int64(2), GetDeliveryStats().Dropped)
+}
+
+func TestLoggerDeliveryMaxRetryDuration(t *testing.T) {
+	var attempts int64
+	origin := sendLog
+	sendLog = func(ctx context.Context, data interface{}) error {
+		atomic.AddInt64(&attempts, 1)
+		return errors.New("unavailable")
+	}
+	defer func() { sendLog = origin }()
+	useTestDeliverer(t, DeliveryConfig{MaxRetries: 10, Backoff: 20 * time.Millisecond, MaxRetryDuration: 50 * time.Millisecond})
+
+	// 第二次退避（40ms）会超出重试总时长上限，不再重试
+	start := time.Now()
+	l := NewLogger(context.Background())
+	l.Infof("log")
+	Send(context.Background(), l)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&attempts))
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, 1, len(spoolFiles(t)))
+}
+
+func TestLoggerSpoolLimits(t *testing.T) {
+	origin := sendLog
+	sendLog = func(ctx context.Context, data interface{}) error { return errors.New("unavailable") }
+	defer func() { sendLog = origin }()
+	useTestDeliverer(t, DeliveryConfig{MaxRetries: -1, MaxSpoolBytes: 1024, MaxSpoolAge: time.Hour})
+
+	// 超出大小上限时淘汰最早的批次
+	for i := 0; i < 20; i++ {
+		l := NewLogger(context.Background())
+		l.Infof("log %d", i)
+		Send(context.Background(), l)
+	}
+	stats := GetDeliveryStats()
+	files := spoolFiles(t)
+	assert.Equal(t, int64(40), stats.Spooled)
+	assert.True(t, stats.Dropped > 0)
+	assert.Equal(t, int64(40), stats.Dropped+int64(2*len(files)))
+	var total int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		assert.Nil(t, err)
+		total += info.Size()
+	}
+	assert.True(t, total <= 1024)
+
+	// 超出保留时间的批次在补发时丢弃
+	old := time.Now().Add(-2 * time.Hour)
+	for _, file := range files {
+		assert.Nil(t, os.Chtimes(file, old, old))
+	}
+	ResendSpooledLogs(context.Background())
+	assert.Equal(t, 0, len(spoolFiles(t)))
+	assert.Equal(t, int64(40), GetDeliveryStats().Dropped)
+}
+
+func TestLoggerSinks(t *testing.T) {
+	var buf bytes.Buffer
+	SetSinks(NewWriterSink(&buf))
+	defer SetSinks()
+
+	memory := NewMemorySink()
+	l := NewConsoleLogger(context.Background())
+	l.AddSink(memory)
+	l.SetLevel(utils.LogLevelInfo)
+
+	l.With(String("k", "v")).Infof("hello %d", 1)
+	l.Debugf("filtered")
+	l.Errorw("failed", Int("code", 2))
+
+	entries := memory.Entries()
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "hello 1", entries[0].Message)
+	assert.Equal(t, "v", entries[0].Fields["k"])
+	assert.Equal(t, utils.LogLevelError, entries[1].Level)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	var log utils.FormatLog
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &log))
+	assert.Equal(t, "failed", log.Message)
+
+	memory.Reset()
+	assert.Equal(t, 0, len(memory.Entries()))
+}
+
+func TestRotatingFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "function.log")
+	sink, err := NewRotatingFileSink(path, 200, 2)
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, sink.Write(&utils.FormatLog{Level: utils.LogLevelInfo, Message: strings.Repeat("x", 50)}))
+	}
+	assert.Nil(t, sink.Close())
+	assert.NotNil(t, sink.Write(&utils.FormatLog{}))
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		assert.Nil(t, err)
+		assert.True(t, info.Size() <= 200)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// 默认仅属主可读写，已存在的文件同样修正权限
+	for _, name := range []string{path, path + ".1"} {
+		info, _ := os.Stat(name)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+	assert.Nil(t, os.Chmod(path, 0644))
+	sink, err = NewRotatingFileSinkWithMode(path, 200, 2, 0640)
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Close())
+	info, _ := os.Stat(path)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestLoggerSampling(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	l := NewLogger(context.Background())
+	l.SetClock(fake)
+	l.SetSampling(SamplingConfig{Initial: 2, Thereafter: 3})
+
+	for i := 0; i < 10; i++ {
+		l.Infof("loop %d", i) // 记录第 1, 2, 5, 8 条
+	}
+	l.Infof("other")
+	assert.Equal(t, 5, len(l.logs))
+	assert.Equal(t, int64(5), l.infoNum)
+
+	// 每秒上限
+	l.SetSampling(SamplingConfig{PerSecond: 2})
+	for i := 0; i < 5; i++ {
+		l.Warnw("burst")
+	}
+	fake.Advance(time.Second)
+	l.Warnw("burst")
+	assert.Equal(t, 8, len(l.logs))
+
+	l.addLog("", utils.LogLevelInfo, AggregationLog, nil)
+	var aggregation Log
+	assert.Nil(t, json.Unmarshal([]byte(l.logs[len(l.logs)-1]), &aggregation))
+	assert.Equal(t, 1, len(aggregation.ExtraInfo.SuppressedLogs))
+	assert.Equal(t, "burst", aggregation.ExtraInfo.SuppressedLogs[0].Format)
+	assert.Equal(t, int64(3), aggregation.ExtraInfo.SuppressedLogs[0].Count)
+	assert.True(t, strings.HasPrefix(aggregation.ExtraInfo.SuppressedLogs[0].CallSite, "logger_test.go:"))
+	assert.Contains(t, aggregation.Tags, Tag{Key: "suppressedNum", Value: "3"})
+}
+
+func TestLoggerSamplingAdapter(t *testing.T) {
+	l := NewLogger(context.Background())
+	l.SetSampling(SamplingConfig{Initial: 1})
+	w := NewWriter(SetLogger(context.Background(), l), utils.LogLevelInfo)
+
+	// 适配器无法确定用户调用位置，按格式串分别统计
+	for i := 0; i < 3; i++ {
+		_, _ = w.Write([]byte("connect\n"))
+		_, _ = w.Write([]byte("retry\n"))
+	}
+	l.Infof("direct")
+	l.Infof("direct")
+
+	items, total := l.getSampler().summary()
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, SuppressedLog{Format: "connect", Count: 2}, items[0])
+	assert.Equal(t, SuppressedLog{Format: "retry", Count: 2}, items[1])
+	assert.Equal(t, "direct", items[2].Format)
+	assert.True(t, strings.HasPrefix(items[2].CallSite, "logger_test.go:"))
+}
+
+func TestLoggerSamplingStdlog(t *testing.T) {
+	l := NewLogger(context.Background())
+	l.SetSampling(SamplingConfig{Initial: 2})
+	std := stdlog.New(NewWriter(SetLogger(context.Background(), l), utils.LogLevelInfo), "", stdlog.LstdFlags|stdlog.Lmicroseconds)
+
+	// 时间前缀与参数不同的同一模板日志按同一个 key 采样
+	for i := 0; i < 10; i++ {
+		std.Printf("retry order %d", i)
+	}
+	assert.Equal(t, 2, len(l.logs))
+	items, total := l.getSampler().summary()
+	assert.Equal(t, int64(8), total)
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, "#/#/# #:#:#.# retry order #", items[0].Format)
+
+	// 采样计数有上限
+	s := newSampler(SamplingConfig{Initial: 1})
+	for i := 0; i < maxSamplingKeys*2; i++ {
+		s.allow(fmt.Sprintf("key %d", i), 0)
+		s.recordSuppressed("", fmt.Sprintf("key %d", i))
+	}
+	assert.True(t, len(s.counts) <= maxSamplingKeys)
+	assert.Equal(t, maxSamplingKeys+1, len(s.suppressed))
+}
+
+func TestLoggerRedaction(t *testing.T) {
+	// 默认不脱敏
+	l := NewLogger(context.Background())
+	l.Infof("phone %s", "13800138000")
+	var log Log
+	assert.Nil(t, json.Unmarshal([]byte(l.logs[0]), &log))
+	assert.Equal(t, "phone 13800138000", log.Content)
+
+	// 开启本次调用的脱敏
+	memory := NewMemorySink()
+	l = NewLogger(utils.SetRedactionToCtx(context.Background(), true))
+	l.AddSink(memory)
+	l.Infof("phone %s", "13800138000")
+	l.Infow("login", String("email", "a@example.com"))
+
+	assert.Nil(t, json.Unmarshal([]byte(l.logs[0]), &log))
+	assert.Equal(t, "phone ***", log.Content)
+	assert.Nil(t, json.Unmarshal([]byte(l.logs[1]), &log))
+	assert.Equal(t, "***", log.Fields["email"])
+	assert.Equal(t, "phone ***", memory.Entries()[0].Message)
+
+	// 全局开启后可按调用关闭
+	r, _ := utils.NewRedactor(utils.RedactConfig{})
+	utils.SetDefaultRedactor(r)
+	defer utils.SetDefaultRedactor(nil)
+	l = NewLogger(context.Background())
+	l.Infof("phone %s", "13800138000")
+	assert.Nil(t, json.Unmarshal([]byte(l.logs[0]), &log))
+	assert.Equal(t, "phone ***", log.Content)
+	l = NewLogger(utils.SetRedactionToCtx(context.Background(), false))
+	l.Infof("phone %s", "13800138000")
+	assert.Nil(t, json.Unmarshal([]byte(l.logs[0]), &log))
+	assert.Equal(t, "phone 13800138000", log.Content)
+}
+
+func decodeLogs(t *testing.T, l *Logger) []Log {
+	logs := make([]Log, len(l.logs))
+	for i, item := range l.logs {
+		assert.Nil(t, json.Unmarshal([]byte(item), &logs[i]))
+	}
+	return logs
+}
+
+func TestLoggerAdapters(t *testing.T) {
+	l := NewLogger(context.Background())
+	ctx := SetLogger(context.Background(), l)
+
+	std := stdlog.New(NewWriter(ctx, utils.LogLevelWarn), "lib: ", 0)
+	std.Print("first")
+	std.Print("second\nthird")
+
+	hooked := logrus.New()
+	hooked.SetOutput(io.Discard)
+	hooked.AddHook(NewLogrusHook(context.Background()))
+	hooked.WithContext(ctx).WithError(errors.New("boom")).WithField("id", 1).Error("failed")
+	hooked.WithContext(ctx).Debug("filtered by level")
+
+	logs := decodeLogs(t, l)
+	assert.Equal(t, 4, len(logs))
+	assert.Equal(t, "lib: first", logs[0].Content)
+	assert.Equal(t, "third", logs[2].Content)
+	assert.Equal(t, utils.LogLevelWarn, logs[2].Level)
+	assert.Equal(t, "failed", logs[3].Content)
+	assert.Equal(t, utils.LogLevelError, logs[3].Level)
+	assert.Equal(t, "boom", logs[3].Fields["error"])
+	assert.Equal(t, int64(1), l.errorNum)
+	assert.Equal(t, int64(3), l.warnNum)
+}
+
+func TestDecodeLogBatch(t *testing.T) {
+	l := NewLogger(context.Background())
+	l.Infof("first")
+	l.Errorw("long", String("k", "v"))
+	l.Warnf("%s", strings.Repeat("x", utils.LogLengthLimit+1))
+	l.addLog("", utils.LogLevelInfo, AggregationLog, nil)
+
+	items := append([]string(nil), l.logs[:1]...)
+	items = append(items, l.logs[2:]...)                // 丢失第二条
+	items = append(items, l.logs[3][:len(l.logs[3])/2]) // 截断的条目
+	data, _ := json.Marshal(items)
+	compressData, err := CompressForDeflate(data)
+	assert.Nil(t, err)
+
+	plain, err := DecompressForDeflate(compressData)
+	assert.Nil(t, err)
+	assert.Equal(t, data, plain)
+
+	body, _ := json.Marshal(map[string]string{"compressData": compressData})
+	for _, payload := range []string{compressData, string(body) + "\n"} {
+		batch, err := DecodeLogBatch(payload)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(batch.Logs))
+		assert.Equal(t, AggregationLog, batch.Logs[2].Type)
+		assert.Equal(t, []LogBatchIssue{
+			{Index: 1, Sequence: 4, Message: "sequence gap: expected 3, got 4"},
+			{Index: 1, Sequence: 4, Message: "content truncated: exceeds length limit"},
+		}, batch.Issues[:2])
+		assert.Equal(t, 3, batch.Issues[2].Index)
+		assert.True(t, strings.HasPrefix(batch.Issues[2].Message, "invalid log entry"))
+	}
+
+	_, err = DecodeLogBatch("not base64")
+	assert.NotNil(t, err)
+	_, err = DecodeLogBatch(`{"compressData":""}`)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package logger
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	maxSamplingKeys      = 1024 // 采样计数最多记录的格式串数量，超出时重置计数，避免内存无限增长
+	maxSamplingKeyLength = 256  // 适配器日志的采样 key 最大长度
+	overflowSamplingKey  = "<other>"
+)
+
+// SamplingConfig 日志采样配置，避免循环中的高频日志耗尽 LogCountLimit
+type SamplingConfig struct {
+	Initial    int // 同一格式串的前 N 条全部记录，<= 0 表示不按格式串采样
+	Thereafter int // 超过 Initial 后每 M 条记录一条，<= 0 表示之后全部丢弃
+	PerSecond  int // 每秒最多记录的日志条数，<= 0 表示不限制
+}
+
+func (c SamplingConfig) enabled() bool {
+	return c.Initial > 0 || c.PerSecond > 0
+}
+
+// SuppressedLog 按调用位置与格式串统计被采样丢弃的日志，记录在聚合日志中
+type SuppressedLog struct {
+	CallSite string `json:"callSite,omitempty"` // 通过适配器记录的日志无法确定调用位置，为空
+	Format   string `json:"format"`
+	Count    int64  `json:"count"`
+}
+
+var defaultSamplingConfig atomic.Value // SamplingConfig
+
+// SetDefaultSamplingConfig 设置新建 Logger 默认使用的采样配置
+func SetDefaultSamplingConfig(conf SamplingConfig) {
+	defaultSamplingConfig.Store(conf)
+}
+
+func getDefaultSamplingConfig() SamplingConfig {
+	conf, _ := defaultSamplingConfig.Load().(SamplingConfig)
+	return conf
+}
+
+type sampler struct {
+	conf SamplingConfig
+
+	lock        sync.Mutex
+	counts      map[string]int64 // 格式串 -> 出现次数
+	second      int64            // 当前秒级窗口
+	secondCount int
+	suppressed  map[suppressedKey]*SuppressedLog
+}
+
+type suppressedKey struct {
+	callSite, format string
+}
+
+func newSampler(conf SamplingConfig) *sampler {
+	if !conf.enabled() {
+		return nil
+	}
+	return &sampler{
+		conf:       conf,
+		counts:     make(map[string]int64),
+		suppressed: make(map[suppressedKey]*SuppressedLog),
+	}
+}
+
+// allow 判断该格式串的日志是否记录，nowSecond 为当前时间的秒级时间戳
+func (s *sampler) allow(format string, nowSecond int64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conf.Initial > 0 {
+		if _, ok := s.counts[format]; !ok && len(s.counts) >= maxSamplingKeys {
+			s.counts = make(map[string]int64)
+		}
+		s.counts[format]++
+		if n := s.counts[format]; n > int64(s.conf.Initial) {
+			if s.conf.Thereafter <= 0 || (n-int64(s.conf.Initial))%int64(s.conf.Thereafter) != 0 {
+				return false
+			}
+		}
+	}
+
+	if s.conf.PerSecond > 0 {
+		if nowSecond != s.second {
+			s.second, s.secondCount = nowSecond, 0
+		}
+		if s.secondCount >= s.conf.PerSecond {
+			return false
+		}
+		s.secondCount++
+	}
+	return true
+}
+
+func (s *sampler) recordSuppressed(callSite, format string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := suppressedKey{callSite: callSite, format: format}
+	item, ok := s.suppressed[key]
+	if !ok && len(s.suppressed) >= maxSamplingKeys {
+		key = suppressedKey{format: overflowSamplingKey}
+		item, ok = s.suppressed[key]
+	}
+	if !ok {
+		item = &SuppressedLog{CallSite: key.callSite, Format: key.format}
+		s.suppressed[key] = item
+	}
+	item.Count++
+}
+
+// summary 按丢弃条数降序返回各调用位置的统计
+func (s *sampler) summary() ([]SuppressedLog, int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var total int64
+	items := make([]SuppressedLog, 0, len(s.suppressed))
+	for _, item := range s.suppressed {
+		items = append(items, *item)
+		total += item.Count
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		if items[i].CallSite != items[j].CallSite {
+			return items[i].CallSite < items[j].CallSite
+		}
+		return items[i].Format < items[j].Format
+	})
+	return items, total
+}
+
+// SetSampling 修改当前调用的采样配置，会重置已有的采样计数
+func (l *Logger) SetSampling(conf SamplingConfig) {
+	root := l.root()
+	root.samplerLock.Lock()
+	defer root.samplerLock.Unlock()
+	root.sampler = newSampler(conf)
+}
+
+func (l *Logger) getSampler() *sampler {
+	l.samplerLock.RLock()
+	defer l.samplerLock.RUnlock()
+	return l.sampler
+}
+
+// sampled 判断日志是否通过采样，需由 logf/logw 直接调用；callerSkip 为 logf/logw 的调用方到用户代码的栈帧数，
+// < 0 表示适配器日志，按 samplingTemplate 归一化后的内容统计
+func (l *Logger) sampled(format string, callerSkip int) bool {
+	root := l.root()
+	s := root.getSampler()
+	if s == nil {
+		return true
+	}
+	if callerSkip < 0 {
+		format = samplingTemplate(format)
+	}
+	if s.allow(format, root.now().Unix()) {
+		return true
+	}
+
+	// 0: sampled, 1: logf/logw, 2: Infof 等入口
+	var callSite string
+	if callerSkip >= 0 {
+		callSite = "unknown"
+		if _, file, line, ok := runtime.Caller(2 + callerSkip); ok {
+			callSite = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+		}
+	}
+	s.recordSuppressed(callSite, format)
+	return false
+}
+
+// samplingTemplate 适配器日志已格式化，将数字替换为 '#' 作为采样 key，
+// 使标准库 log 的时间前缀、行号及参数中的 ID 等不影响统计
+func samplingTemplate(msg string) string {
+	var b strings.Builder
+	digits := false
+	for _, r := range msg {
+		if b.Len() >= maxSamplingKeyLength {
+			break
+		}
+		if r >= '0' && r <= '9' {
+			if !digits {
+				b.WriteByte('#')
+			}
+			digits = true
+			continue
+		}
+		digits = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,236 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/byted-apaas/server-common-go/utils"
+)
+
+const (
+	DefaultRotatingFileMaxBytes   = 100 * 1024 * 1024 // 100MB
+	DefaultRotatingFileMaxBackups = 3
+	DefaultRotatingFileMode       = os.FileMode(0600) // 日志可能包含业务数据，默认仅属主可读写
+)
+
+// Sink 日志输出目标，Logger 在写入平台日志的同时写入所有 sink，用于将函数日志转发到自建采集端
+type Sink interface {
+	Write(log *utils.FormatLog) error
+	Close() error
+}
+
+var globalSinks atomic.Value // []Sink
+
+// SetSinks 设置所有 Logger 共用的 sink，传空表示清空
+func SetSinks(sinks ...Sink) {
+	globalSinks.Store(append([]Sink(nil), sinks...))
+}
+
+// GetSinks 获取所有 Logger 共用的 sink
+func GetSinks() []Sink {
+	sinks, _ := globalSinks.Load().([]Sink)
+	return sinks
+}
+
+// AddSink 为当前调用的 Logger 追加 sink，子 logger 与父 logger 共享 sink
+func (l *Logger) AddSink(sinks ...Sink) {
+	root := l.root()
+	root.sinkLock.Lock()
+	defer root.sinkLock.Unlock()
+	root.sinks = append(append([]Sink(nil), root.sinks...), sinks...)
+}
+
+func (l *Logger) writeSinks(level int, msg string, fields map[string]interface{}) {
+	l.sinkLock.RLock()
+	local := l.sinks
+	l.sinkLock.RUnlock()
+
+	global := GetSinks()
+	if len(global) == 0 && len(local) == 0 {
+		return
+	}
+	log := l.newFormatLog(level, msg, fields)
+	for _, sinks := range [][]Sink{global, local} {
+		for _, sink := range sinks {
+			if err := sink.Write(log); err != nil {
+				utils.GetConsoleLogger(l.RequestID).Errorf("[Logger] write sink failed, err: %v", err)
+			}
+		}
+	}
+}
+
+// WriterSink 以 JSON lines 格式写入 io.Writer
+type WriterSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(log *utils.FormatLog) error {
+	b, err := utils.JsonMarshalBytes(log)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+// Close 不关闭底层 io.Writer，由调用方负责
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// RotatingFileSink 以 JSON lines 格式写入本地文件，文件超过大小上限时轮转为 path.1 ... path.N
+type RotatingFileSink struct {
+	lock       sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	mode       os.FileMode
+
+	file *os.File
+	size int64
+}
+
+// NewRotatingFileSink maxBytes <= 0 时使用默认上限，maxBackups < 0 时使用默认备份数，maxBackups == 0 表示不保留备份，
+// 文件权限为 DefaultRotatingFileMode
+func NewRotatingFileSink(path string, maxBytes int64, maxBackups int) (*RotatingFileSink, error) {
+	return NewRotatingFileSinkWithMode(path, maxBytes, maxBackups, DefaultRotatingFileMode)
+}
+
+// NewRotatingFileSinkWithMode 指定日志文件权限，mode 为 0 时使用 DefaultRotatingFileMode，已存在的文件也会修改为该权限
+func NewRotatingFileSinkWithMode(path string, maxBytes int64, maxBackups int, mode os.FileMode) (*RotatingFileSink, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultRotatingFileMaxBytes
+	}
+	if maxBackups < 0 {
+		maxBackups = DefaultRotatingFileMaxBackups
+	}
+	if mode == 0 {
+		mode = DefaultRotatingFileMode
+	}
+	s := &RotatingFileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups, mode: mode.Perm()}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RotatingFileSink) Write(log *utils.FormatLog) error {
+	b, err := utils.JsonMarshalBytes(log)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("rotating file sink %s is closed", s.path)
+	}
+	if s.size > 0 && s.size+int64(len(b)) > s.maxBytes {
+		if err = s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+func (s *RotatingFileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *RotatingFileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, s.mode)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err == nil && info.Mode().Perm() != s.mode { // 已存在的文件或受 umask 影响时修正权限
+		err = file.Chmod(s.mode)
+	}
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *RotatingFileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	if s.maxBackups == 0 {
+		_ = os.Remove(s.path)
+	} else {
+		_ = os.Remove(s.backupPath(s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(s.backupPath(i), s.backupPath(i+1))
+		}
+		if err := os.Rename(s.path, s.backupPath(1)); err != nil {
+			return err
+		}
+	}
+	return s.open()
+}
+
+func (s *RotatingFileSink) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
+
+// MemorySink 将日志保存在内存中，用于测试
+type MemorySink struct {
+	lock sync.Mutex
+	logs []utils.FormatLog
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Write(log *utils.FormatLog) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.logs = append(s.logs, *log)
+	return nil
+}
+
+// Entries 返回已写入日志的副本
+func (s *MemorySink) Entries() []utils.FormatLog {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]utils.FormatLog(nil), s.logs...)
+}
+
+func (s *MemorySink) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.logs = nil
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
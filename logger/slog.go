@@ -0,0 +1,100 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+//go:build go1.21
+// +build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/byted-apaas/server-common-go/utils"
+)
+
+// SlogHandler 将 slog 日志记录到 ctx 中的 Logger，Handle 的 ctx 中有 Logger 时优先使用
+type SlogHandler struct {
+	ctx    context.Context
+	attrs  []Field
+	prefix string // WithGroup 的分组前缀，如 "req."
+}
+
+func NewSlogHandler(ctx context.Context) *SlogHandler {
+	return &SlogHandler{ctx: ctx}
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return loggerForAdapter(ctx, h.ctx).Enabled(slogLevelToLogLevel(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := append([]Field(nil), h.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.prefix, attr)
+		return true
+	})
+	loggerForAdapter(ctx, h.ctx).logw(slogLevelToLogLevel(record.Level), -1, record.Message, fields)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := *h
+	child.attrs = append([]Field(nil), h.attrs...)
+	for _, attr := range attrs {
+		child.attrs = appendSlogAttr(child.attrs, h.prefix, attr)
+	}
+	return &child
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	child := *h
+	child.prefix = h.prefix + name + "."
+	return &child
+}
+
+// appendSlogAttr 展开分组属性，key 以 "." 连接分组名
+func appendSlogAttr(fields []Field, prefix string, attr slog.Attr) []Field {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix = prefix + attr.Key + "."
+		}
+		for _, child := range value.Group() {
+			fields = appendSlogAttr(fields, prefix, child)
+		}
+		return fields
+	}
+	if attr.Key == "" {
+		return fields
+	}
+
+	v := value.Any()
+	switch t := v.(type) {
+	case error:
+		v = t.Error()
+	case interface{ String() string }:
+		if value.Kind() == slog.KindDuration || value.Kind() == slog.KindTime || value.Kind() == slog.KindAny {
+			v = t.String()
+		}
+	}
+	return append(fields, Field{Key: prefix + attr.Key, Value: v})
+}
+
+func slogLevelToLogLevel(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return utils.LogLevelError
+	case level >= slog.LevelWarn:
+		return utils.LogLevelWarn
+	case level >= slog.LevelInfo:
+		return utils.LogLevelInfo
+	case level >= slog.LevelDebug:
+		return utils.LogLevelDebug
+	default:
+		return utils.LogLevelTrace
+	}
+}
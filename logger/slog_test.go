@@ -0,0 +1,35 @@
+//go:build go1.21
+// +build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/byted-apaas/server-common-go/utils"
+)
+
+func TestSlogHandler(t *testing.T) {
+	l := NewLogger(context.Background())
+	l.SetLevel(utils.LogLevelDebug)
+	ctx := SetLogger(context.Background(), l)
+
+	logger := slog.New(NewSlogHandler(ctx)).With("service", "order").WithGroup("req")
+	logger.Debug("debug", "id", 1)
+	logger.Warn("slow", "cost", 2*time.Second, slog.Group("user", "name", "u1"))
+	logger.Log(context.Background(), slog.LevelDebug-4, "trace filtered")
+
+	logs := decodeLogs(t, l)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, utils.LogLevelDebug, logs[0].Level)
+	assert.Equal(t, "order", logs[0].Fields["service"])
+	assert.Equal(t, "slow", logs[1].Content)
+	assert.Equal(t, utils.LogLevelWarn, logs[1].Level)
+	assert.Equal(t, "2s", logs[1].Fields["req.cost"])
+	assert.Equal(t, "u1", logs[1].Fields["req.user.name"])
+}
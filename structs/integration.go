@@ -0,0 +1,18 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package structs
+
+// AppAccessToken 飞书集成 app_access_token
+type AppAccessToken struct {
+	Expire         int64  `json:"expire"` // 过期时间戳，单位：s
+	AppAccessToken string `json:"appAccessToken"`
+	AppID          string `json:"larkAppId"`
+}
+
+// TenantAccessToken 飞书集成 tenant_access_token
+type TenantAccessToken struct {
+	Expire            int64  `json:"expire"` // 过期时间戳，单位：s
+	TenantAccessToken string `json:"tenantAccessToken"`
+	AppID             string `json:"larkAppId"`
+}
@@ -82,6 +82,11 @@ type AppTokenResp struct {
 	TenantInfo  TenantInfo `json:"tenantInfo"`
 }
 
+type ServiceTokenResp struct {
+	AccessToken string `json:"accessToken"`
+	ExpireTime  int64  `json:"expireTime"`
+}
+
 type RPCCliConf struct {
 	Psm         string        `yaml:"Psm" json:"Psm"`
 	DebugAddr   string        `yaml:"DebugAddr" json:"DebugAddr"`
@@ -162,4 +167,5 @@ type SDKConf struct {
 type SDKTransientConf struct {
 	IsCloseMesh        bool  `json:"isCloseMesh"`
 	MeshDestReqTimeout int64 `json:"meshDestReqTimeout"`
+	LogLevel           int   `json:"logLevel"` // 用户日志最低输出级别，取值同 utils.LogLevelXxx，数值越大输出越详细
 }
@@ -0,0 +1,241 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock 时钟接口，便于在测试中替换为 Fake，避免依赖真实时间
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 对应 time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker 对应 time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+var defaultClock Clock = realClock{}
+
+// Default 返回基于 time 包的真实时钟
+func Default() Clock {
+	return defaultClock
+}
+
+// OrDefault c 为 nil 时返回真实时钟
+func OrDefault(c Clock) Clock {
+	if c == nil {
+		return defaultClock
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{t: time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{t: time.NewTicker(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r *realTimer) C() <-chan time.Time {
+	return r.t.C
+}
+
+func (r *realTimer) Stop() bool {
+	return r.t.Stop()
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (r *realTicker) C() <-chan time.Time {
+	return r.t.C
+}
+
+func (r *realTicker) Stop() {
+	r.t.Stop()
+}
+
+func (r *realTicker) Reset(d time.Duration) {
+	r.t.Reset(d)
+}
+
+// Fake 手动推进的时钟，只有调用 Advance/Set 时时间才会流逝
+type Fake struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFake 创建以 now 为初始时间的 Fake 时钟
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.addWaiter(d, 0)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for Fake.NewTicker")
+	}
+	return &fakeTicker{fakeWaiter: f.addWaiter(d, d)}
+}
+
+// Advance 推进时间，按触发时间顺序触发到期的 Timer 与 Ticker
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set 设置当前时间，时间不会回退
+func (f *Fake) Set(t time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if t.Before(f.now) {
+		return
+	}
+	f.now = t
+
+	sort.SliceStable(f.waiters, func(i, j int) bool {
+		return f.waiters[i].when.Before(f.waiters[j].when)
+	})
+	remain := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.when.After(t) {
+			remain = append(remain, w)
+			continue
+		}
+		w.fire(w.when)
+		if w.period > 0 { // 与 time.Ticker 一致，丢弃错过的 tick
+			for !w.when.After(t) {
+				w.when = w.when.Add(w.period)
+			}
+			remain = append(remain, w)
+		}
+	}
+	f.waiters = remain
+}
+
+// Waiters 返回尚未触发或停止的 Timer 与 Ticker 数量，用于测试中等待 goroutine 就绪
+func (f *Fake) Waiters() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.waiters)
+}
+
+func (f *Fake) addWaiter(d, period time.Duration) *fakeWaiter {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	w := &fakeWaiter{
+		clock:  f,
+		when:   f.now.Add(d),
+		period: period,
+		ch:     make(chan time.Time, 1),
+	}
+	if d <= 0 && period == 0 {
+		w.fire(f.now)
+		return w
+	}
+	f.waiters = append(f.waiters, w)
+	return w
+}
+
+func (f *Fake) removeWaiter(target *fakeWaiter) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i, w := range f.waiters {
+		if w == target {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeWaiter struct {
+	clock  *Fake
+	when   time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+func (w *fakeWaiter) fire(t time.Time) {
+	select {
+	case w.ch <- t:
+	default:
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *fakeWaiter) Stop() bool {
+	return w.clock.removeWaiter(w)
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.removeWaiter(t.fakeWaiter)
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.removeWaiter(t.fakeWaiter)
+
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	t.when = t.clock.now.Add(d)
+	t.period = d
+	t.clock.waiters = append(t.clock.waiters, t.fakeWaiter)
+}
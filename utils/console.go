@@ -6,7 +6,10 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -22,7 +25,12 @@ const (
 	gray   = 37
 )
 
+// DefaultConsoleTimeFormat 控制台日志默认时间格式
+const DefaultConsoleTimeFormat = "15:04:05.999"
+
 type LogFormatter struct {
+	DisableColors bool
+	TimeFormat    string // 为空时使用 DefaultConsoleTimeFormat
 }
 
 func (m *LogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
@@ -34,11 +42,22 @@ func (m *LogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	}
 
 	level, color := LevelInfo(entry.Level)
-	msg := fmt.Sprintf("%s %s %s", level, entry.Time.Format("15:04:05.999"), entry.Message)
+	if entry.Level == logrus.TraceLevel {
+		level, color = consoleTraceLevelInfo(entry)
+	}
+	timeFormat := m.TimeFormat
+	if timeFormat == "" {
+		timeFormat = DefaultConsoleTimeFormat
+	}
+	msg := fmt.Sprintf("%s %s %s", level, entry.Time.Format(timeFormat), entry.Message)
 	if entry.Level == logrus.DebugLevel {
-		msg = fmt.Sprintf("%s %s %s", entry.Time.Format("15:04:05.999"), level, entry.Message)
+		msg = fmt.Sprintf("%s %s %s", entry.Time.Format(timeFormat), level, entry.Message)
+	}
+	if m.DisableColors {
+		b.WriteString(msg + "\n")
+	} else {
+		b.WriteString(fmt.Sprintf("\x1b[%dm%s\x1b[0m\n", color, msg))
 	}
-	b.WriteString(fmt.Sprintf("\x1b[%dm%s\x1b[0m\n", color, msg))
 	return b.Bytes(), nil
 }
 
@@ -93,6 +112,8 @@ func WebIDELevelInfo(level logrus.Level) string {
 		return "error"
 	case logrus.WarnLevel:
 		return "warn"
+	case logrus.TraceLevel:
+		return "debug"
 	default:
 		return "info"
 	}
@@ -113,19 +134,69 @@ func LevelInfo(level logrus.Level) (string, int) {
 	return "[NULL] ", gray
 }
 
-type ConsoleLogger struct {
-	logID string
+// consoleLevelKey logrus 的 Debug 级别用于输出函数结果，debug/trace 日志统一使用 Trace 级别，并通过该字段区分
+const consoleLevelKey = "apaas_level"
+
+func consoleTraceLevelInfo(entry *logrus.Entry) (string, int) {
+	if entry.Data[consoleLevelKey] == "trace" {
+		return "[TRACE]", gray
+	}
+	return "[DEBUG]", gray
 }
 
-func GetConsoleLogger(logIDs ...string) *ConsoleLogger {
-	if os.Getenv(ISWebIDE) == "true" {
-		logrus.SetFormatter(&WebIDELogFormatter{})
+// ConsoleConfig 控制台日志配置
+type ConsoleConfig struct {
+	Output        io.Writer // 为空时输出到 os.Stderr
+	DisableColors bool
+	TimeFormat    string // 为空时使用 DefaultConsoleTimeFormat
+	WebIDE        bool   // 以 WebIDE 的 JSON 格式输出
+}
+
+// DefaultConsoleConfig 默认配置，根据 IS_WEB_IDE 环境变量决定是否使用 WebIDE 格式
+func DefaultConsoleConfig() ConsoleConfig {
+	return ConsoleConfig{WebIDE: os.Getenv(ISWebIDE) == "true"}
+}
+
+func newConsoleLogrus(conf ConsoleConfig) *logrus.Logger {
+	l := logrus.New()
+	if conf.Output != nil {
+		l.SetOutput(conf.Output)
+	}
+	if conf.WebIDE {
+		l.SetFormatter(&WebIDELogFormatter{})
 	} else {
-		logrus.SetFormatter(&LogFormatter{})
+		l.SetFormatter(&LogFormatter{DisableColors: conf.DisableColors, TimeFormat: conf.TimeFormat})
 	}
-	logrus.SetLevel(logrus.TraceLevel)
+	l.SetLevel(logrus.TraceLevel)
+	return l
+}
+
+var (
+	consoleLogrus     atomic.Value // *logrus.Logger
+	consoleLogrusOnce sync.Once
+)
 
-	l := &ConsoleLogger{}
+// SetConsoleConfig 修改控制台日志配置，对之后获取的 ConsoleLogger 生效，不影响全局 logrus
+func SetConsoleConfig(conf ConsoleConfig) {
+	consoleLogrusOnce.Do(func() {})
+	consoleLogrus.Store(newConsoleLogrus(conf))
+}
+
+func getConsoleLogrus() *logrus.Logger {
+	consoleLogrusOnce.Do(func() {
+		consoleLogrus.Store(newConsoleLogrus(DefaultConsoleConfig()))
+	})
+	return consoleLogrus.Load().(*logrus.Logger)
+}
+
+// ConsoleLogger 控制台日志，使用独立的 logrus 实例，不修改全局 logrus 的配置
+type ConsoleLogger struct {
+	logID  string
+	logger *logrus.Logger
+}
+
+func GetConsoleLogger(logIDs ...string) *ConsoleLogger {
+	l := &ConsoleLogger{logger: getConsoleLogrus()}
 	if len(logIDs) > 0 && logIDs[0] != "" {
 		l.logID = logIDs[0]
 	}
@@ -136,23 +207,37 @@ func (c *ConsoleLogger) Infof(format string, args ...interface{}) {
 	if c.logID != "" {
 		format = fmt.Sprintf("%s %s", c.logID, format)
 	}
-	logrus.Infof(format, args...)
+	c.logger.Infof(format, args...)
 }
 
 func (c *ConsoleLogger) Warnf(format string, args ...interface{}) {
 	if c.logID != "" {
 		format = fmt.Sprintf("%s %s", c.logID, format)
 	}
-	logrus.Warnf(format, args...)
+	c.logger.Warnf(format, args...)
 }
 
 func (c *ConsoleLogger) Errorf(format string, args ...interface{}) {
 	if c.logID != "" {
 		format = fmt.Sprintf("%s %s", c.logID, format)
 	}
-	logrus.Errorf(format, args...)
+	c.logger.Errorf(format, args...)
 }
 
 func (c *ConsoleLogger) Result(format string, args ...interface{}) {
-	logrus.Debugf(format, args...)
+	c.logger.Debugf(format, args...)
+}
+
+func (c *ConsoleLogger) Debugf(format string, args ...interface{}) {
+	if c.logID != "" {
+		format = fmt.Sprintf("%s %s", c.logID, format)
+	}
+	c.logger.WithField(consoleLevelKey, "debug").Tracef(format, args...)
+}
+
+func (c *ConsoleLogger) Tracef(format string, args ...interface{}) {
+	if c.logID != "" {
+		format = fmt.Sprintf("%s %s", c.logID, format)
+	}
+	c.logger.WithField(consoleLevelKey, "trace").Tracef(format, args...)
 }
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/byted-apaas/server-common-go/structs"
+)
+
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestConsoleLogger(t *testing.T) {
+	defer SetConsoleConfig(DefaultConsoleConfig())
+
+	formatter, level := logrus.StandardLogger().Formatter, logrus.GetLevel()
+	out := &syncBuffer{}
+	SetConsoleConfig(ConsoleConfig{Output: out, DisableColors: true, TimeFormat: "15:04"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			GetConsoleLogger("log-1").Infof("hello %d", 1)
+		}()
+	}
+	wg.Wait()
+	GetConsoleLogger().Tracef("trace")
+
+	// 不修改全局 logrus 配置
+	assert.Equal(t, formatter, logrus.StandardLogger().Formatter)
+	assert.Equal(t, level, logrus.GetLevel())
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 11, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "[INFO]  "))
+	assert.True(t, strings.HasSuffix(lines[0], " log-1 hello 1"))
+	assert.NotContains(t, lines[0], "\x1b[")
+	assert.True(t, strings.HasPrefix(lines[10], "[TRACE] "))
+
+	// WebIDE JSON 格式
+	out = &syncBuffer{}
+	SetConsoleConfig(ConsoleConfig{Output: out, WebIDE: true})
+	GetConsoleLogger().Warnf("careful")
+	var log structs.WebIDELog
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &log))
+	assert.Equal(t, "warn", log.Level)
+	assert.Equal(t, "careful", log.Message)
+}
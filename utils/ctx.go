@@ -686,6 +686,28 @@ func IsCloseMesh(ctx context.Context) bool {
 	return transientConf.IsCloseMesh
 }
 
+// SetLogLevelToCtx 设置本次调用的用户日志级别，级别数值大于 level 的日志不输出
+func SetLogLevelToCtx(ctx context.Context, level int) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, constants.CtxKeyLogLevel, level)
+}
+
+// GetLogLevelFromCtx 获取用户日志级别，优先级：ctx > SDKConf > 默认 info
+func GetLogLevelFromCtx(ctx context.Context) int {
+	if ctx == nil {
+		return LogLevelInfo
+	}
+	if level, ok := ctx.Value(constants.CtxKeyLogLevel).(int); ok && level > 0 {
+		return level
+	}
+	if conf := GetSDKTransientConf(ctx); conf != nil && conf.LogLevel > 0 {
+		return conf.LogLevel
+	}
+	return LogLevelInfo
+}
+
 func GetTraceHeader(ctx context.Context) map[string]string {
 	traceHeader := map[string]string{}
 	if ctx == nil {
@@ -737,18 +759,63 @@ func IsRuntime(ctx context.Context) bool {
 	return GetRuntimeType(ctx) == RuntimeTypeRuntime
 }
 
+// RequestPriority 请求优先级，用于实例级限流时按优先级放行
+type RequestPriority int
+
+const (
+	RequestPriorityCritical       RequestPriority = iota + 1 // 关键请求，不受限流影响，也不占用配额
+	RequestPriorityInfrastructure                            // 基础设施请求（获取 token、上报日志等），占用配额但不会被拒绝
+	RequestPriorityInteractive                               // 业务交互请求，默认优先级，可使用全部配额
+	RequestPriorityBatch                                     // 批量请求，只能使用部分配额，为交互请求预留余量
+)
+
+func (p RequestPriority) String() string {
+	switch p {
+	case RequestPriorityCritical:
+		return "critical"
+	case RequestPriorityInfrastructure:
+		return "infrastructure"
+	case RequestPriorityInteractive:
+		return "interactive"
+	case RequestPriorityBatch:
+		return "batch"
+	default:
+		return "unknown"
+	}
+}
+
+// SetRequestPriorityToCtx 设置请求优先级
+func SetRequestPriorityToCtx(ctx context.Context, priority RequestPriority) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, constants.CtxKeyRequestPriority, priority)
+}
+
+// GetRequestPriorityFromCtx 获取请求优先级，未设置时默认为 RequestPriorityInteractive
+func GetRequestPriorityFromCtx(ctx context.Context) RequestPriority {
+	if ctx == nil {
+		return RequestPriorityInteractive
+	}
+	cast, ok := ctx.Value(constants.CtxKeyRequestPriority).(RequestPriority)
+	if !ok || cast < RequestPriorityCritical || cast > RequestPriorityBatch {
+		return RequestPriorityInteractive
+	}
+	return cast
+}
+
 // GetAPaaSPersistFaaSPressureSignalId 获取request_source中的压力信号id
 func GetAPaaSPersistFaaSPressureSignalId(ctx context.Context) string {
 
 	reqSrc := GetAPaaSPersistFaaSValueFromCtx(ctx, constants.PersistFaaSKeyRequestSource)
 	if reqSrc == "" {
-		fmt.Println("GetAPaaSPersistFaaSValueFromCtx request_source is empty")
+		fmt.Println(NewFormatLog(ctx, LogLevelWarn, constants.PressureLogType, "request_source is empty").String())
 		return ""
 	}
 
 	value, err := jsonparser.GetString([]byte(reqSrc), constants.RequestSourcePressureSignalId)
 	if err != nil { // maybe key not exist
-		fmt.Println("GetAPaaSPersistFaaSPressureSignalId jsonparser get key error: ", err.Error())
+		fmt.Println(NewFormatLog(ctx, LogLevelWarn, constants.PressureLogType, "get pressure signal id from request_source failed: "+err.Error()).String())
 		return ""
 	}
 
@@ -12,6 +12,8 @@ const (
 	LogLevelError = 4
 	LogLevelWarn  = 5
 	LogLevelInfo  = 6
+	LogLevelDebug = 7
+	LogLevelTrace = 8
 
 	LogCountLimit     = 10000
 	LogLengthLimit    = 10000
@@ -20,7 +22,7 @@ const (
 )
 
 type FormatLog struct {
-	Level         int    `json:"level"`           // 日志级别, 4-error,5-warn,6-info
+	Level         int    `json:"level"`           // 日志级别, 4-error,5-warn,6-info,7-debug,8-trace
 	EventID       string `json:"event_id"`        // 事件 ID，可观测需要
 	FunctionAPIID string `json:"function_api_id"` // 函数 API ID
 	LogID         string `json:"log_id"`          // 日志 ID，事件编号与日志编号有一一对应关系
@@ -30,6 +32,8 @@ type FormatLog struct {
 	TenantType    int64  `json:"tenant_type"`     // 租户 ID
 	Namespace     string `json:"namespace"`       // 命名空间
 	LogType       string `json:"log_type"`        // 日志类型
+
+	Fields map[string]interface{} `json:"fields,omitempty"` // 结构化字段
 }
 
 func NewFormatLog(ctx context.Context, level int, logType, message string) *FormatLog {
@@ -74,8 +78,10 @@ func GetFormatDate() string {
 }
 
 type SpeedDownMessage struct {
-	Key       string `json:"key"`
-	SleepTime int32  `json:"sleep_time"` // 降速时间，单位：毫秒
+	Key             string `json:"key"`
+	SleepTime       int32  `json:"sleep_time"`        // 降速时间，单位：毫秒
+	ActualSleepTime int64  `json:"actual_sleep_time"` // 实际降速时间（含抖动、提前结束），单位：毫秒
+	Error           string `json:"error,omitempty"`   // 降速被中断或快速失败的原因
 }
 
 type SDKCallLogMessage struct {
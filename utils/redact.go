@@ -0,0 +1,286 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/byted-apaas/server-common-go/constants"
+	cExceptions "github.com/byted-apaas/server-common-go/exceptions"
+)
+
+// DefaultRedactMask 脱敏后的替换内容
+const DefaultRedactMask = "***"
+
+// RedactConfig 脱敏配置
+type RedactConfig struct {
+	DisableBuiltin bool     // 关闭内置的手机号、邮箱、身份证号、token 检测
+	Patterns       []string // 自定义正则，匹配内容整体替换为 Mask
+	JSONPaths      []string // 点分 JSON 路径，如 data.user.phone，数组按元素展开，* 匹配任意 key
+	Mask           string   // 为空时使用 DefaultRedactMask
+}
+
+// Redactor 日志脱敏器，nil 表示不脱敏
+type Redactor struct {
+	detectors []redactDetector
+	jsonPaths [][]string
+	mask      string
+}
+
+type redactDetector struct {
+	pattern  *regexp.Regexp
+	boundary bool // 匹配内容前后不能是字母或数字，避免误伤更长的数字串
+	keep     int  // 保留的前缀分组序号，如 token=xxx 中的 "token="，0 表示整体替换
+}
+
+var builtinRedactDetectors = []redactDetector{
+	{pattern: regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-_.~+/=]+`), keep: 1},
+	{pattern: regexp.MustCompile(`(?i)("?(?:[a-z]+_?)?(?:token|secret|password|passwd)"?\s*[:=]\s*"?)[^"\s,&}]+`), keep: 1},
+	{pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{pattern: regexp.MustCompile(`[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[0-9Xx]`), boundary: true},
+	{pattern: regexp.MustCompile(`(?:\+?86[\- ]?)?1[3-9]\d{9}`), boundary: true},
+}
+
+func NewRedactor(conf RedactConfig) (*Redactor, error) {
+	r := &Redactor{mask: conf.Mask}
+	if r.mask == "" {
+		r.mask = DefaultRedactMask
+	}
+	if !conf.DisableBuiltin {
+		r.detectors = append(r.detectors, builtinRedactDetectors...)
+	}
+	for _, p := range conf.Patterns {
+		pattern, err := regexp.Compile(p)
+		if err != nil {
+			return nil, cExceptions.InternalError("invalid redact pattern %s, err: %v", p, err)
+		}
+		r.detectors = append(r.detectors, redactDetector{pattern: pattern})
+	}
+	for _, p := range conf.JSONPaths {
+		if p = strings.TrimSpace(p); p != "" {
+			r.jsonPaths = append(r.jsonPaths, strings.Split(p, "."))
+		}
+	}
+	return r, nil
+}
+
+// Redact 对文本脱敏，文本为 JSON 时先按 JSONPaths 脱敏；没有命中任何规则时原样返回
+func (r *Redactor) Redact(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	if len(r.jsonPaths) > 0 {
+		s = r.redactJSON(s)
+	}
+	for _, d := range r.detectors {
+		s = r.replace(d, s)
+	}
+	return s
+}
+
+// RedactFields 对结构化字段脱敏，返回新的 map，不修改入参。
+// 嵌套的 map、slice、结构体按 JSON 序列化后的结构递归脱敏，无法序列化的值原样保留
+func (r *Redactor) RedactFields(fields map[string]interface{}) map[string]interface{} {
+	if r == nil || len(fields) == 0 {
+		return fields
+	}
+	var root interface{} = copyJSONValue(fields)
+	for _, path := range r.jsonPaths {
+		root = r.maskPath(root, path, nil)
+	}
+	return r.redactValue(root).(map[string]interface{})
+}
+
+// redactValue 对值中所有字符串递归脱敏
+func (r *Redactor) redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return r.Redact(t)
+	case map[string]interface{}:
+		for k, child := range t {
+			t[k] = r.redactValue(child)
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = r.redactValue(child)
+		}
+	}
+	return v
+}
+
+func (r *Redactor) replace(d redactDetector, s string) string {
+	matches := d.pattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if d.boundary && (isAlnumAt(s, start-1) || isAlnumAt(s, end)) {
+			continue
+		}
+		if d.keep > 0 && m[2*d.keep] >= 0 {
+			start = m[2*d.keep+1]
+		}
+		sb.WriteString(s[last:start])
+		sb.WriteString(r.mask)
+		last = end
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+func isAlnumAt(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (r *Redactor) redactJSON(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return s
+	}
+	var root interface{}
+	if err := JsonUnmarshalBytes([]byte(trimmed), &root); err != nil {
+		return s
+	}
+	matched := false
+	for _, path := range r.jsonPaths {
+		root = r.maskPath(root, path, &matched)
+	}
+	// 未命中任何路径时保留原文，避免重新序列化改变 key 顺序与格式
+	if !matched {
+		return s
+	}
+	b, err := JsonMarshalBytes(root)
+	if err != nil {
+		return s
+	}
+	return string(bytes.TrimSpace(b))
+}
+
+// maskPath 将 path 命中的值替换为 mask，matched 非 nil 时记录是否有命中
+func (r *Redactor) maskPath(v interface{}, path []string, matched *bool) interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		for i := range t {
+			t[i] = r.maskPath(t[i], path, matched)
+		}
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return r.masked(matched)
+		}
+		for k, child := range t {
+			if path[0] != "*" && path[0] != k {
+				continue
+			}
+			if len(path) == 1 {
+				t[k] = r.masked(matched)
+			} else {
+				t[k] = r.maskPath(child, path[1:], matched)
+			}
+		}
+	default:
+		if len(path) == 0 {
+			return r.masked(matched)
+		}
+	}
+	return v
+}
+
+func (r *Redactor) masked(matched *bool) string {
+	if matched != nil {
+		*matched = true
+	}
+	return r.mask
+}
+
+// copyJSONValue 深拷贝为 JSON 通用结构，结构体、自定义 map/slice 等通过 JSON 序列化转换
+func copyJSONValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, string, bool, float64, int, int64:
+		return v
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			m[k] = copyJSONValue(child)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, child := range t {
+			s[i] = copyJSONValue(child)
+		}
+		return s
+	}
+	b, err := JsonMarshalBytes(v)
+	if err != nil {
+		return v
+	}
+	var value interface{}
+	if err = JsonUnmarshalBytes(b, &value); err != nil {
+		return v
+	}
+	return value
+}
+
+type redactorHolder struct {
+	redactor *Redactor
+}
+
+var (
+	defaultRedactor atomic.Value // redactorHolder
+
+	// builtinRedactor 仅使用内置检测规则的脱敏器，SetRedactionToCtx 开启脱敏且未设置默认脱敏器时使用
+	builtinRedactor = &Redactor{detectors: builtinRedactDetectors, mask: DefaultRedactMask}
+)
+
+// SetDefaultRedactor 设置默认脱敏器，nil 表示默认不脱敏
+func SetDefaultRedactor(r *Redactor) {
+	defaultRedactor.Store(redactorHolder{redactor: r})
+}
+
+// GetDefaultRedactor 获取默认脱敏器，未设置时为 nil，即默认不脱敏
+func GetDefaultRedactor() *Redactor {
+	holder, _ := defaultRedactor.Load().(redactorHolder)
+	return holder.redactor
+}
+
+// SetRedactorToCtx 设置本次调用使用的脱敏器，nil 表示本次调用不脱敏
+func SetRedactorToCtx(ctx context.Context, r *Redactor) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, constants.CtxKeyRedactor, r)
+}
+
+// SetRedactionToCtx 开启或关闭本次调用的脱敏，开启时使用默认脱敏器，未设置默认脱敏器时使用内置检测规则
+func SetRedactionToCtx(ctx context.Context, enabled bool) context.Context {
+	if !enabled {
+		return SetRedactorToCtx(ctx, nil)
+	}
+	if r := GetDefaultRedactor(); r != nil {
+		return SetRedactorToCtx(ctx, r)
+	}
+	return SetRedactorToCtx(ctx, builtinRedactor)
+}
+
+// GetRedactorFromCtx 获取本次调用使用的脱敏器，优先级：ctx > 默认脱敏器，返回 nil 表示不脱敏
+func GetRedactorFromCtx(ctx context.Context) *Redactor {
+	if ctx != nil {
+		if r, ok := ctx.Value(constants.CtxKeyRedactor).(*Redactor); ok {
+			return r
+		}
+	}
+	return GetDefaultRedactor()
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactorBuiltin(t *testing.T) {
+	r, err := NewRedactor(RedactConfig{})
+	assert.NoError(t, err)
+
+	cases := []struct{ in, out string }{
+		{"call 13800138000 now", "call *** now"},
+		{"+86 13800138000,13900139000", "***,***"},
+		{"order 1138001380001 kept", "order 1138001380001 kept"},
+		{"mail: dev.ops+1@example.com", "mail: ***"},
+		{"id 11010519491231002X ok", "id *** ok"},
+		{"Authorization: Bearer abc.def-123", "Authorization: Bearer ***"},
+		{`{"accessToken":"T:xyz","expire":7200}`, `{"accessToken":"***","expire":7200}`},
+		{"url?client_secret=s3cr3t&x=1", "url?client_secret=***&x=1"},
+		{"nothing sensitive", "nothing sensitive"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.out, r.Redact(c.in), c.in)
+	}
+
+	var disabled *Redactor
+	assert.Equal(t, "13800138000", disabled.Redact("13800138000"))
+}
+
+func TestRedactorCustom(t *testing.T) {
+	_, err := NewRedactor(RedactConfig{Patterns: []string{"("}})
+	assert.Error(t, err)
+
+	r, err := NewRedactor(RedactConfig{
+		DisableBuiltin: true,
+		Patterns:       []string{`ORD-\d+`},
+		JSONPaths:      []string{"data.items.owner", "*.bankCard"},
+		Mask:           "[masked]",
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "order [masked] by 13800138000", r.Redact("order ORD-42 by 13800138000"))
+	assert.Equal(t,
+		`{"data":{"bankCard":"[masked]","items":[{"id":1,"owner":"[masked]"},{"id":2,"owner":"[masked]"}]}}`,
+		r.Redact(`{"data":{"items":[{"id":1,"owner":"a"},{"id":2,"owner":"b"}],"bankCard":"6222"}}`))
+	assert.Equal(t, "not json {", r.Redact("not json {"))
+
+	fields := map[string]interface{}{"data": map[string]interface{}{"bankCard": "6222"}, "note": "ORD-1"}
+	redacted := r.RedactFields(fields)
+	assert.Equal(t, map[string]interface{}{"data": map[string]interface{}{"bankCard": "[masked]"}, "note": "[masked]"}, redacted)
+	assert.Equal(t, "6222", fields["data"].(map[string]interface{})["bankCard"])
+}
+
+func TestRedactFieldsNested(t *testing.T) {
+	r, err := NewRedactor(RedactConfig{JSONPaths: []string{"user.bankCard"}})
+	assert.NoError(t, err)
+
+	type user struct {
+		Name     string `json:"name"`
+		Phone    string `json:"phone"`
+		BankCard string `json:"bankCard"`
+	}
+	fields := map[string]interface{}{
+		"user":   &user{Name: "dev", Phone: "13800138000", BankCard: "6222"},
+		"emails": []string{"dev@example.com", "ops"},
+		"meta":   map[string]interface{}{"contact": []interface{}{"call 13800138000"}, "count": 2},
+		"labels": map[string]string{"owner": "dev@example.com"},
+	}
+	redacted := r.RedactFields(fields)
+	assert.Equal(t, map[string]interface{}{"name": "dev", "phone": "***", "bankCard": "***"}, redacted["user"])
+	assert.Equal(t, []interface{}{"***", "ops"}, redacted["emails"])
+	assert.Equal(t, []interface{}{"call ***"}, redacted["meta"].(map[string]interface{})["contact"])
+	assert.Equal(t, 2, redacted["meta"].(map[string]interface{})["count"])
+	assert.Equal(t, map[string]interface{}{"owner": "***"}, redacted["labels"])
+
+	// 不修改入参
+	assert.Equal(t, "13800138000", fields["user"].(*user).Phone)
+	assert.Equal(t, "call 13800138000", fields["meta"].(map[string]interface{})["contact"].([]interface{})[0])
+}
+
+func TestRedactorFromCtx(t *testing.T) {
+	// 默认不脱敏，需显式开启
+	assert.Nil(t, GetDefaultRedactor())
+	assert.Nil(t, GetRedactorFromCtx(context.Background()))
+	assert.Nil(t, GetRedactorFromCtx(SetRedactionToCtx(context.Background(), false)))
+	builtin := GetRedactorFromCtx(SetRedactionToCtx(context.Background(), true))
+	assert.NotNil(t, builtin)
+	assert.Equal(t, "call *** now", builtin.Redact("call 13800138000 now"))
+
+	custom, err := NewRedactor(RedactConfig{DisableBuiltin: true, Patterns: []string{`ORD-\d+`}})
+	assert.NoError(t, err)
+	SetDefaultRedactor(custom)
+	defer SetDefaultRedactor(nil)
+	assert.Same(t, custom, GetRedactorFromCtx(context.Background()))
+	assert.Same(t, custom, GetRedactorFromCtx(SetRedactionToCtx(context.Background(), true)))
+	assert.Nil(t, GetRedactorFromCtx(SetRedactionToCtx(context.Background(), false)))
+}
+
+func TestRedactorKeepsUnmatchedBody(t *testing.T) {
+	r, err := NewRedactor(RedactConfig{JSONPaths: []string{"data.phone"}})
+	assert.NoError(t, err)
+
+	// 未命中任何规则时原样返回，不重新序列化
+	body := `{ "z": 1, "a": {"b": [1, 2]},  "data": {"name": "n"} }`
+	assert.Equal(t, body, r.Redact(body))
+	assert.Contains(t, r.Redact(`{"z":1,"data":{"phone":"p"}}`), `"phone":"***"`)
+}
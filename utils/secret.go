@@ -0,0 +1,215 @@
+// Copyright 2022 ByteDance Ltd. and/or its affiliates
+// SPDX-License-Identifier: MIT
+
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/byted-apaas/server-common-go/constants"
+)
+
+// 加密信封格式：v2:<keyID>:<base64url(nonce|ciphertext|tag)>，使用 AES-GCM，版本号与 keyID 作为附加数据参与认证。
+// 不带版本前缀的文本视为旧格式（AES-CBC，hex 编码）。
+const SecretEnvelopeVersion = "v2"
+
+// SecretKeyring 密钥环，按 keyID 管理多个密钥：使用主密钥加密，按信封中的 keyID 解密，便于密钥轮换
+type SecretKeyring struct {
+	lock        sync.RWMutex
+	keys        map[string][]byte
+	decryptOnly map[string]bool // 仅用于解密的密钥，不能作为主密钥
+	primary     string
+	legacyKey   []byte
+}
+
+func NewSecretKeyring() *SecretKeyring {
+	return &SecretKeyring{keys: make(map[string][]byte), decryptOnly: make(map[string]bool)}
+}
+
+// NewSecretKeyringFromEnv 从环境变量构建密钥环：
+// 旧格式密钥来自 KTenantName+KNamespace，仅用于解密旧格式文本；KSecretKeys 中的密钥依次加入，第一个为主密钥。
+// v2 信封只能使用 KSecretKeys 中的密钥解密，未配置 KSecretKeys 时没有主密钥，Encrypt 返回错误
+func NewSecretKeyringFromEnv() (*SecretKeyring, error) {
+	keyring := NewSecretKeyring()
+	keyring.SetLegacyKey(LegacySecretKey(os.Getenv(constants.EnvKTenantName), os.Getenv(constants.EnvKNamespace)))
+
+	for _, item := range strings.Split(os.Getenv(constants.EnvKSecretKeys), ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("illegal secret key %q, want keyID:base64Key", item)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("illegal secret key %q: %v", parts[0], err)
+		}
+		if err = keyring.AddKey(parts[0], key); err != nil {
+			return nil, err
+		}
+	}
+	return keyring, nil
+}
+
+// IsSecretEnvelope 文本是否为 v2 信封
+func IsSecretEnvelope(text string) bool {
+	return strings.HasPrefix(text, SecretEnvelopeVersion+":")
+}
+
+// LegacySecretKey 旧格式的密钥：tenantName+namespace 补 '0' 或截断至 32 字节
+func LegacySecretKey(tenantName, namespace string) []byte {
+	return paddingN([]byte(tenantName+namespace), 32)
+}
+
+// AddKey 添加密钥，key 长度需为 16/24/32 字节；第一个添加的密钥为主密钥
+func (k *SecretKeyring) AddKey(keyID string, key []byte) error {
+	return k.addKey(keyID, key, false)
+}
+
+// AddDecryptKey 添加仅用于解密的密钥，不会作为主密钥
+func (k *SecretKeyring) AddDecryptKey(keyID string, key []byte) error {
+	return k.addKey(keyID, key, true)
+}
+
+func (k *SecretKeyring) addKey(keyID string, key []byte, decryptOnly bool) error {
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return fmt.Errorf("illegal key id %q", keyID)
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("illegal key %q: %v", keyID, err)
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.keys[keyID] = append([]byte(nil), key...)
+	if decryptOnly {
+		k.decryptOnly[keyID] = true
+		if k.primary == keyID {
+			k.primary = ""
+		}
+		return nil
+	}
+	delete(k.decryptOnly, keyID)
+	if k.primary == "" {
+		k.primary = keyID
+	}
+	return nil
+}
+
+// SetPrimary 设置用于加密的主密钥
+func (k *SecretKeyring) SetPrimary(keyID string) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if _, ok := k.keys[keyID]; !ok {
+		return fmt.Errorf("key %q not found", keyID)
+	}
+	if k.decryptOnly[keyID] {
+		return fmt.Errorf("key %q is decrypt-only", keyID)
+	}
+	k.primary = keyID
+	return nil
+}
+
+// Primary 当前主密钥 ID
+func (k *SecretKeyring) Primary() string {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.primary
+}
+
+// SetLegacyKey 设置旧格式解密使用的密钥
+func (k *SecretKeyring) SetLegacyKey(key []byte) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.legacyKey = append([]byte(nil), key...)
+}
+
+// Encrypt 使用主密钥加密，未配置主密钥时返回错误
+func (k *SecretKeyring) Encrypt(plainText string) (string, error) {
+	k.lock.RLock()
+	keyID, key := k.primary, k.keys[k.primary]
+	k.lock.RUnlock()
+	if keyID == "" {
+		return "", fmt.Errorf("no primary key, configure %s", constants.EnvKSecretKeys)
+	}
+	return AesGcmEncryptText(keyID, key, plainText)
+}
+
+// Decrypt 解密 v2 信封或旧格式文本
+func (k *SecretKeyring) Decrypt(text string) (string, error) {
+	if !IsSecretEnvelope(text) {
+		k.lock.RLock()
+		legacyKey := k.legacyKey
+		k.lock.RUnlock()
+		return AesDecryptText(0, legacyKey, text)
+	}
+
+	parts := strings.SplitN(text, ":", 3)
+	if len(parts) != 3 {
+		return "", fmt.Errorf("illegal secret envelope")
+	}
+	k.lock.RLock()
+	key, ok := k.keys[parts[1]]
+	k.lock.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("key %q not found", parts[1])
+	}
+	return AesGcmDecryptText(key, text)
+}
+
+// AesGcmEncryptText 使用 AES-GCM 加密并生成 v2 信封
+func AesGcmEncryptText(keyID string, key []byte, plainText string) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plainText), envelopeAAD(keyID))
+	return SecretEnvelopeVersion + ":" + keyID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// AesGcmDecryptText 解密 v2 信封，密文或 keyID 被篡改时返回错误
+func AesGcmDecryptText(key []byte, envelope string) (string, error) {
+	parts := strings.SplitN(envelope, ":", 3)
+	if len(parts) != 3 || parts[0] != SecretEnvelopeVersion {
+		return "", fmt.Errorf("illegal secret envelope")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("illegal secret envelope: %v", err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", fmt.Errorf("illegal secret envelope: too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], envelopeAAD(parts[1]))
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret envelope failed: %v", err)
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func envelopeAAD(keyID string) []byte {
+	return []byte(SecretEnvelopeVersion + ":" + keyID)
+}
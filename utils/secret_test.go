@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func legacyEncrypt(t *testing.T, key []byte, plainText string) string {
+	iv, err := getInitialVector("0")
+	assert.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	assert.NoError(t, err)
+	pad := aes.BlockSize - len(plainText)%aes.BlockSize
+	src := append([]byte(plainText), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	dst := make([]byte, len(src))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(dst, src)
+	return hex.EncodeToString(dst)
+}
+
+func TestSecretKeyring(t *testing.T) {
+	for k, v := range map[string]string{"KTenantName": "tenant", "KNamespace": "ns__c", "KSecretKeys": "k1:" + strings.Repeat("A", 43) + "="} {
+		old, ok := os.LookupEnv(k)
+		_ = os.Setenv(k, v)
+		defer func(k, old string, ok bool) {
+			if ok {
+				_ = os.Setenv(k, old)
+			} else {
+				_ = os.Unsetenv(k)
+			}
+		}(k, old, ok)
+	}
+
+	keyring, err := NewSecretKeyringFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", keyring.Primary())
+
+	// 旧格式兼容
+	legacy := legacyEncrypt(t, LegacySecretKey("tenant", "ns__c"), "client-id")
+	plain, err := keyring.Decrypt(legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, "client-id", plain)
+
+	// v2 信封加解密
+	envelope, err := keyring.Encrypt("client-secret")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(envelope, "v2:k1:"))
+	plain, err = keyring.Decrypt(envelope)
+	assert.NoError(t, err)
+	assert.Equal(t, "client-secret", plain)
+
+	// 密钥轮换：新主密钥加密，旧密钥仍可解密
+	assert.NoError(t, keyring.AddKey("k2", bytes.Repeat([]byte{1}, 32)))
+	assert.NoError(t, keyring.SetPrimary("k2"))
+	rotated, _ := keyring.Encrypt("client-secret")
+	assert.True(t, strings.HasPrefix(rotated, "v2:k2:"))
+	plain, err = keyring.Decrypt(envelope)
+	assert.NoError(t, err)
+	assert.Equal(t, "client-secret", plain)
+
+	// 篡改密文或 keyID 时解密失败
+	tampered := envelope[:len(envelope)-2] + "AA"
+	_, err = keyring.Decrypt(tampered)
+	assert.Error(t, err)
+	_, err = keyring.Decrypt(strings.Replace(envelope, "v2:k1:", "v2:ns:", 1))
+	assert.Error(t, err)
+
+	// 非法的旧格式输入返回错误而非 panic
+	for _, text := range []string{"", "0g", "0011"} {
+		_, err = keyring.Decrypt(text)
+		assert.Error(t, err)
+	}
+}
+
+func TestSecretKeyringNoDerivedKey(t *testing.T) {
+	for k, v := range map[string]string{"KTenantName": "tenant", "KNamespace": "ns__c", "KSecretKeys": ""} {
+		old, ok := os.LookupEnv(k)
+		_ = os.Setenv(k, v)
+		defer func(k, old string, ok bool) {
+			if ok {
+				_ = os.Setenv(k, old)
+			} else {
+				_ = os.Unsetenv(k)
+			}
+		}(k, old, ok)
+	}
+
+	// 未配置 KSecretKeys 时没有主密钥，加密失败
+	keyring, err := NewSecretKeyringFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "", keyring.Primary())
+	_, err = keyring.Encrypt("client-secret")
+	assert.Error(t, err)
+
+	// 由公开的 tenantName+namespace 派生密钥伪造的信封不能解密
+	derived := sha256.Sum256([]byte("tenantns__c"))
+	forged, err := AesGcmEncryptText("ns", derived[:], "client-secret")
+	assert.NoError(t, err)
+	_, err = keyring.Decrypt(forged)
+	assert.Error(t, err)
+
+	// 配置的密钥成为主密钥
+	assert.NoError(t, keyring.AddKey("k1", bytes.Repeat([]byte{1}, 32)))
+	assert.Equal(t, "k1", keyring.Primary())
+}
+
+func TestGetAppIDAndSecretMalformedSecretKeys(t *testing.T) {
+	clientID := legacyEncrypt(t, LegacySecretKey("tenant", "ns__c"), "client-id")
+	clientSecret := legacyEncrypt(t, LegacySecretKey("tenant", "ns__c"), "client-secret")
+	envs := map[string]string{
+		"KTenantName":   "tenant",
+		"KNamespace":    "ns__c",
+		"KClientID":     clientID,
+		"KClientSecret": clientSecret,
+		"KSecretKeys":   "malformed",
+	}
+	for k, v := range envs {
+		old, ok := os.LookupEnv(k)
+		_ = os.Setenv(k, v)
+		defer func(k, old string, ok bool) {
+			if ok {
+				_ = os.Setenv(k, old)
+			} else {
+				_ = os.Unsetenv(k)
+			}
+		}(k, old, ok)
+	}
+
+	// 旧格式不依赖 KSecretKeys
+	id, secret, err := GetAppIDAndSecret()
+	assert.NoError(t, err)
+	assert.Equal(t, "client-id", id)
+	assert.Equal(t, "client-secret", secret)
+
+	// v2 信封需要 KSecretKeys，配置错误时返回错误
+	envelope, err := AesGcmEncryptText("k1", bytes.Repeat([]byte{1}, 32), "client-secret")
+	assert.NoError(t, err)
+	_ = os.Setenv("KClientSecret", envelope)
+	_, _, err = GetAppIDAndSecret()
+	assert.Error(t, err)
+}
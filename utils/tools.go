@@ -73,17 +73,24 @@ func aesCbsDecrypt(cipherText, iv []byte, key []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(cipherText) == 0 || len(cipherText)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("illegal cipher text length %d", len(cipherText))
+	}
 	blockMode := cipher.NewCBCDecrypter(block, iv)
 	plainText := make([]byte, len(cipherText))
 	blockMode.CryptBlocks(plainText, cipherText)
-	plainText = unPaddingN(plainText)
-	return plainText, nil
+	return unPaddingN(plainText)
 }
 
-func unPaddingN(cipherText []byte) []byte {
-	end := cipherText[len(cipherText)-1]
-	cipherText = cipherText[:len(cipherText)-int(end)]
-	return cipherText
+func unPaddingN(cipherText []byte) ([]byte, error) {
+	if len(cipherText) == 0 {
+		return nil, fmt.Errorf("illegal padding: empty text")
+	}
+	end := int(cipherText[len(cipherText)-1])
+	if end == 0 || end > len(cipherText) {
+		return nil, fmt.Errorf("illegal padding %d", end)
+	}
+	return cipherText[:len(cipherText)-end], nil
 }
 
 func TimeMils(t time.Time) int64 {
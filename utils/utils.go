@@ -98,12 +98,20 @@ func GetAppIDAndSecret() (string, string, error) {
 		return "", "", exp.InternalError("Missing params in env.")
 	}
 
-	key := paddingN([]byte(tenantName+namespace), 32)
-	clientID, err := AesDecryptText(0, key, dClientID)
+	// 旧格式只依赖 KTenantName+KNamespace，仅在存在 v2 信封时加载 KSecretKeys
+	keyring := NewSecretKeyring()
+	keyring.SetLegacyKey(LegacySecretKey(tenantName, namespace))
+	if IsSecretEnvelope(dClientID) || IsSecretEnvelope(dClientSecret) {
+		var err error
+		if keyring, err = NewSecretKeyringFromEnv(); err != nil {
+			return "", "", exp.InternalError("Load secret keys err: %v", err)
+		}
+	}
+	clientID, err := keyring.Decrypt(dClientID)
 	if err != nil {
 		return "", "", exp.InternalError("Decrypt ClientID err: %v", err)
 	}
-	clientSecret, err := AesDecryptText(0, key, dClientSecret)
+	clientSecret, err := keyring.Decrypt(dClientSecret)
 	if err != nil {
 		return "", "", exp.InternalError("Decrypt ClientSecret err: %v", err)
 	}